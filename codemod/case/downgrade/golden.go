@@ -3,7 +3,9 @@ package ifelse
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gregwebs/try"
@@ -54,3 +56,70 @@ func ifErr() (_ bool, err error) {
 	}
 	return true, nil
 }
+
+func cut(s string) (string, string, error) {
+	before, after, found := strings.Cut(s, "=")
+	if !found {
+		return "", "", errors.New("no =")
+	}
+	return before, after, nil
+}
+
+func span(s string) (time.Time, time.Time, time.Duration, error) {
+	start, end, err := cut(s)
+	if err != nil {
+		return time.Time{}, time.Time{}, 0, err
+	}
+	st, err := time.Parse(idxTimeFmt, start)
+	if err != nil {
+		return time.Time{}, time.Time{}, 0, err
+	}
+	et, err := time.Parse(idxTimeFmt, end)
+	if err != nil {
+		return time.Time{}, time.Time{}, 0, err
+	}
+	return st, et, et.Sub(st), nil
+}
+
+func parseID(s string) (_ int64, err error) {
+	id, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return try.Zero[int64](), err
+	}
+	return id, nil
+}
+
+func pair(s string) (_ string, _ string, err error) {
+	key, value, err := cut(s)
+	if err != nil {
+		return try.Zero[string](), try.Zero[string](), err
+	}
+	return key, value, nil
+}
+
+func duration(s string) (_ time.Duration, err error) {
+	_, _, d, err := span(s)
+	if err != nil {
+		return try.Zero[time.Duration](), err
+	}
+	return d, nil
+}
+
+func validate(s string) (err error) {
+	created, err := time.Parse(idxTimeFmt, s)
+	if err != nil {
+		return fmt.Errorf("parse %s: %w", s, err)
+	}
+	key, _, err := cut(s)
+	if err != nil {
+		return fmt.Errorf("cut: %w", err)
+	}
+	_, _, d, err := span(s)
+	if err != nil {
+		return fmt.Errorf("span: %w", err)
+	}
+	if created.IsZero() || key == "" || d < 0 {
+		return errors.New("invalid")
+	}
+	return nil
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gregwebs/try"
@@ -46,3 +47,56 @@ func ifErr() (_ bool, err error) {
 	try.Check(err)
 	return true, nil
 }
+
+func cut(s string) (string, string, error) {
+	before, after, found := strings.Cut(s, "=")
+	if !found {
+		return "", "", errors.New("no =")
+	}
+	return before, after, nil
+}
+
+func span(s string) (time.Time, time.Time, time.Duration, error) {
+	start, end, err := cut(s)
+	if err != nil {
+		return time.Time{}, time.Time{}, 0, err
+	}
+	st, err := time.Parse(idxTimeFmt, start)
+	if err != nil {
+		return time.Time{}, time.Time{}, 0, err
+	}
+	et, err := time.Parse(idxTimeFmt, end)
+	if err != nil {
+		return time.Time{}, time.Time{}, 0, err
+	}
+	return st, et, et.Sub(st), nil
+}
+
+func parseID(s string) (_ int64, err error) {
+	defer try.Handle(&err, nil)
+	id := try.Check1(strconv.ParseInt(s, 10, 64))
+	return id, nil
+}
+
+func pair(s string) (_ string, _ string, err error) {
+	defer try.Handle(&err, nil)
+	key, value := try.Check2(cut(s))
+	return key, value, nil
+}
+
+func duration(s string) (_ time.Duration, err error) {
+	defer try.Handle(&err, nil)
+	_, _, d := try.Check3(span(s))
+	return d, nil
+}
+
+func validate(s string) (err error) {
+	defer try.Handle(&err, nil)
+	created := try.Checkw1(time.Parse(idxTimeFmt, s))("parse %s", s)
+	key, _ := try.Checkw2(cut(s))("cut")
+	_, _, d := try.Checkw3(span(s))("span")
+	if created.IsZero() || key == "" || d < 0 {
+		return errors.New("invalid")
+	}
+	return nil
+}
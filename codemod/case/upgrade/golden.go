@@ -22,12 +22,9 @@ func (i addresses) switchParse(b []byte) (_ interface{}, err error) {
 	var d addresses
 	err = json.Unmarshal(b, &d)
 	try.Check(err)
-	id, err := strconv.ParseInt(d.ID, 10, 64)
-	try.Check(err)
-	cr, err := time.Parse(idxTimeFmt, d.CreatedAt)
-	try.Check(err)
-	ud, err := time.Parse(idxTimeFmt, d.UpdatedAt)
-	try.Check(err)
+	id := try.Check1(strconv.ParseInt(d.ID, 10, 64))
+	cr := try.Check1(time.Parse(idxTimeFmt, d.CreatedAt))
+	ud := try.Check1(time.Parse(idxTimeFmt, d.UpdatedAt))
 	s := struct {
 		id int64
 		cr time.Time
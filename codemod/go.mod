@@ -1,6 +1,6 @@
 module github.com/gregwebs/try/codemod
 
-go 1.19
+go 1.21
 
 require (
 	github.com/gregwebs/errors v0.13.0 // indirect
@@ -9,3 +9,5 @@ require (
 	golang.org/x/sys v0.0.0-20220722155257-8c9f86f7a55f // indirect
 	golang.org/x/tools v0.1.12 // indirect
 )
+
+replace github.com/gregwebs/try => ../
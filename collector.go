@@ -0,0 +1,128 @@
+package try
+
+import (
+	stderrors "errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Collector accumulates errors so that many checks can be made and all of the failures reported together.
+// This is useful for validation.
+//
+//	c := try.NewCollector()
+//	c.Addw(validateName(u.Name), "name")
+//	addr := c.Path("address")
+//	addr.Add(validateZip(u.Address.Zip))
+//	c.Check()
+//
+// The zero value is an empty Collector ready to use.
+// A Collector is safe to use concurrently. It must not be copied after first use.
+type Collector struct {
+	path   []string
+	once   sync.Once
+	shared *collected
+}
+
+type collected struct {
+	mu   sync.Mutex
+	errs []error
+}
+
+// NewCollector creates an empty Collector
+func NewCollector() *Collector {
+	return &Collector{shared: &collected{}}
+}
+
+// state gives the errors shared with the Collectors made by Path, creating them for a zero value.
+func (c *Collector) state() *collected {
+	c.once.Do(func() {
+		if c.shared == nil {
+			c.shared = &collected{}
+		}
+	})
+	return c.shared
+}
+
+// Path gives a Collector that adds errors under a field path.
+// The errors are collected together with the errors of c.
+//
+//	c.Path("address").Path("zip").Add(err) // address.zip: err
+func (c *Collector) Path(name string) *Collector {
+	path := make([]string, len(c.path), len(c.path)+1)
+	copy(path, c.path)
+	return &Collector{path: append(path, name), shared: c.state()}
+}
+
+// Add adds an error. A nil error is ignored.
+// It returns true if the error was added.
+func (c *Collector) Add(err error) bool {
+	if err == nil {
+		return false
+	}
+	if len(c.path) > 0 {
+		err = &FieldError{Path: strings.Join(c.path, "."), Err: err}
+	}
+	shared := c.state()
+	shared.mu.Lock()
+	shared.errs = append(shared.errs, err)
+	shared.mu.Unlock()
+	return true
+}
+
+// Addw adds an error annotated with a message, the same as Checkw.
+// A nil error is ignored.
+//
+//	c.Addw(err, "field %s", name)
+func (c *Collector) Addw(err error, format string, args ...interface{}) bool {
+	if err == nil {
+		return false
+	}
+	return c.Add(Fmtw(format, args...)(err))
+}
+
+// Errors gives the errors that have been added
+func (c *Collector) Errors() []error {
+	shared := c.state()
+	shared.mu.Lock()
+	defer shared.mu.Unlock()
+	return append([]error(nil), shared.errs...)
+}
+
+// Err gives the errors that have been added joined with errors.Join.
+// It returns nil if no errors have been added.
+func (c *Collector) Err() error {
+	return stderrors.Join(c.Errors()...)
+}
+
+// Check throws the errors that have been added as one error joined with errors.Join.
+// It is a noop if no errors have been added.
+// The error is thrown with Check, so it can be recovered with a Handle* function.
+func (c *Collector) Check(handlers ...func(error) error) {
+	throw(c.Err(), 1, handlers)
+}
+
+// FieldError is an error added to a Collector under a field path
+type FieldError struct {
+	// Path is the dotted field path
+	Path string
+	Err  error
+}
+
+func (e *FieldError) Error() string { return e.Path + ": " + e.Err.Error() }
+func (e *FieldError) Unwrap() error { return e.Err }
+
+func (e *FieldError) Format(s fmt.State, verb rune) {
+	switch verb {
+	case 'v':
+		if s.Flag('+') {
+			fmt.Fprintf(s, "%+v\n%s", e.Err, e.Path)
+			return
+		}
+		fallthrough
+	case 's':
+		fmt.Fprint(s, e.Error())
+	case 'q':
+		fmt.Fprintf(s, "%q", e.Error())
+	}
+}
@@ -0,0 +1,113 @@
+package try
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+type startKey struct{}
+
+// WithStart records the current time in the context.
+// The ContextError of the context will then give the time elapsed since the start.
+func WithStart(ctx context.Context) context.Context {
+	return context.WithValue(ctx, startKey{}, time.Now())
+}
+
+// ContextError is thrown by CheckCtx.
+// It explains why the context is done.
+// errors.Is(err, context.Canceled) or errors.Is(err, context.DeadlineExceeded) hold in the same way as for ctx.Err().
+type ContextError struct {
+	// Err is ctx.Err()
+	Err error
+	// Cause is context.Cause(ctx) if it is different from Err
+	Cause error
+	// Deadline is the deadline of the context if it has one
+	Deadline time.Time
+	// PastDeadline is how long ago the deadline passed, or 0 if the context does not have a deadline that has passed.
+	// It gives the timing of a context made with context.WithTimeout or context.WithDeadline without WithStart.
+	PastDeadline time.Duration
+	// Elapsed is the time since WithStart was called on the context or 0 if it was not called
+	Elapsed time.Duration
+}
+
+// CtxErr gives a ContextError if the context is done, otherwise nil
+func CtxErr(ctx context.Context) error {
+	err := ctx.Err()
+	if err == nil {
+		return nil
+	}
+	ctxErr := &ContextError{Err: err}
+	if cause := context.Cause(ctx); cause != err {
+		ctxErr.Cause = cause
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		ctxErr.Deadline = deadline
+		if past := time.Since(deadline); past > 0 {
+			ctxErr.PastDeadline = past
+		}
+	}
+	if start, ok := ctx.Value(startKey{}).(time.Time); ok {
+		ctxErr.Elapsed = time.Since(start)
+	}
+	return ctxErr
+}
+
+func (e *ContextError) Error() string {
+	var details []string
+	if e.Cause != nil {
+		details = append(details, "cause: "+e.Cause.Error())
+	}
+	if !e.Deadline.IsZero() {
+		details = append(details, "deadline: "+e.Deadline.Format(time.RFC3339Nano))
+	}
+	if e.PastDeadline != 0 {
+		details = append(details, "past deadline: "+e.PastDeadline.String())
+	}
+	if e.Elapsed != 0 {
+		details = append(details, "elapsed: "+e.Elapsed.String())
+	}
+	if len(details) == 0 {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("%v (%s)", e.Err, strings.Join(details, ", "))
+}
+
+func (e *ContextError) Unwrap() []error {
+	if e.Cause != nil {
+		return []error{e.Err, e.Cause}
+	}
+	return []error{e.Err}
+}
+
+// CheckCtx throws if the context is done.
+// The error is a ContextError that is annotated with the cause, the deadline, the time past the deadline, and the elapsed time.
+// Long running code can call CheckCtx between steps:
+//
+//	for _, item := range items {
+//		try.CheckCtx(ctx)
+//		process(item)
+//	}
+func CheckCtx(ctx context.Context, handlers ...func(error) error) {
+	if ctx.Err() == nil {
+		return
+	}
+	throw(CtxErr(ctx), 1, handlers)
+}
+
+// CheckCtxw is CheckCtx with an annotation, the same as Checkw
+func CheckCtxw(ctx context.Context, format string, args ...interface{}) {
+	if ctx.Err() == nil {
+		return
+	}
+	throw(CtxErr(ctx), 1, []func(error) error{Fmtw(format, args...)})
+}
+
+// CheckCtxf is CheckCtx with an annotation, the same as Checkf
+func CheckCtxf(ctx context.Context, format string, args ...interface{}) {
+	if ctx.Err() == nil {
+		return
+	}
+	throw(CtxErr(ctx), 1, []func(error) error{Fmtf(format, args...)})
+}
@@ -28,8 +28,8 @@ them. The CopyFile example shows how it works:
 	     w, err := os.Create(dst)
 	     try.Try(err, try.Cleanup(func() {
 	     	os.Remove(dst)
-	     })
-	     defer w.Close()
+	     }))
+	     defer try.HandleClose(&err, w)
 
 	     // Try to copy the file. If error occurs now, all previous error handlers
 	     // will be called in the reversed order. And final return error is
@@ -59,7 +59,8 @@ we can write
 # Stack Tracing
 
 By default, try.Try and try.Check will wrap the error so that it has a stack trace
-This can be disabled by setting the `AddStackTrace = false`
+This can be disabled with `try.Config.SetAddStackTrace(false)`
+or by setting the environment variable `TRY_STACK=0`
 
 # Error handling
 
@@ -0,0 +1,166 @@
+package try
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	stderrors "errors"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/gregwebs/try/internal/annotate"
+	"github.com/gregwebs/try/internal/kind"
+	"github.com/gregwebs/try/internal/kv"
+	"github.com/gregwebs/try/internal/panics"
+	"github.com/gregwebs/try/internal/stack"
+)
+
+// Fingerprint gives a hash of the structural identity of an error.
+// It can be used to group errors in logs and alerts without matching their messages.
+// The fingerprint is made from:
+//   - the types of the errors in the chain, and the Kinds, sentinels, and field keys they are annotated with
+//   - the messages of errors created with errors.New, which are usually sentinels.
+//     fmt.Errorf without %w creates the same type of error,
+//     so the words of the message that look like formatted values (numbers, paths, and quoted strings) are not used.
+//   - the format strings given to Checkf and Handlef
+//   - the call sites (function and file) in the stack traces recorded by Check* and Handle*
+//   - the type of the panic of a PanicAnnotated
+//
+// The messages of annotations are not used,
+// so the values given to Checkw, Checkf, Handlew, Handlef, etc. do not change the fingerprint.
+// Line numbers are not used so that the fingerprint does not change when unrelated code moves.
+// FingerprintLines uses the line numbers.
+func Fingerprint(err error) string {
+	return fingerprint(err, false)
+}
+
+// FingerprintLines is Fingerprint, but it also uses the line numbers of the call sites.
+func FingerprintLines(err error) string {
+	return fingerprint(err, true)
+}
+
+func fingerprint(err error, lines bool) string {
+	if err == nil {
+		return ""
+	}
+	f := fingerprinter{lines: lines}
+	f.walk(err, true)
+	sum := sha256.Sum256([]byte(strings.Join(f.parts, "\n")))
+	return hex.EncodeToString(sum[:8])
+}
+
+type fingerprinter struct {
+	lines bool
+	parts []string
+}
+
+func (f *fingerprinter) add(format string, args ...any) {
+	f.parts = append(f.parts, fmt.Sprintf(format, args...))
+}
+
+var errorStringType = fmt.Sprintf("%T", stderrors.New(""))
+
+// walk adds the structure of the error chain.
+// messages tells whether the message of an errors.New error is used:
+// it is not used for the error made from the message of a panic.
+func (f *fingerprinter) walk(err error, messages bool) {
+	if stack.IsLoggable(err) {
+		f.walk(stderrors.Unwrap(err), messages)
+		return
+	}
+	typ := fmt.Sprintf("%T", err)
+	f.add("%s", typ)
+	switch e := err.(type) {
+	case panics.Annotated:
+		f.add("panic %T", e.Panic)
+		if e.Err != nil {
+			f.walk(e.Err, false)
+		}
+		return
+	case *annotate.Formatted:
+		f.add("format %q", e.Format)
+		f.walk(e.Cause, messages)
+		return
+	case *stack.Error:
+		f.frames(e.Frames())
+	case *kind.Error:
+		f.add("kind %s", e.Kind)
+	case *kv.Error:
+		for _, field := range e.Fields {
+			f.add("key %q", field.Key)
+		}
+	case *marked:
+		f.add("mark")
+		f.walk(e.sentinel, true)
+	case *GoroutineError:
+		f.frames([]runtime.Frame{e.Site})
+	}
+
+	switch u := err.(type) {
+	case interface{ Unwrap() error }:
+		if inner := u.Unwrap(); inner != nil {
+			f.walk(inner, messages)
+			return
+		}
+	case interface{ Unwrap() []error }:
+		f.add("(")
+		for _, inner := range u.Unwrap() {
+			if inner != nil {
+				f.walk(inner, messages)
+			}
+		}
+		f.add(")")
+		return
+	}
+
+	// Sentinel errors are identified by their message, other errors by their type
+	switch err.(type) {
+	case kind.Kind, *kind.Sentinel:
+		f.add("%q", err.Error())
+	default:
+		if messages && typ == errorStringType {
+			f.add("%q", messageShape(err.Error()))
+		}
+	}
+}
+
+// messageShape removes the words of a message that look like values given to fmt.Errorf:
+// words with digits or path separators, and quoted strings.
+// Consecutive removed words are replaced by one "_".
+func messageShape(msg string) string {
+	const quotes = "\"'`"
+	var shape []string
+	quote := -1
+	for _, word := range strings.Fields(msg) {
+		trimmed := strings.TrimRight(word, ":,;.")
+		value := quote >= 0 || strings.ContainsAny(word, "0123456789/\\")
+		if quote < 0 && strings.ContainsRune(quotes, rune(word[0])) {
+			quote = int(word[0])
+			value = true
+			trimmed = trimmed[1:]
+		}
+		if quote >= 0 && strings.HasSuffix(trimmed, string(rune(quote))) {
+			quote = -1
+		}
+		if !value {
+			shape = append(shape, word)
+		} else if len(shape) == 0 || shape[len(shape)-1] != "_" {
+			shape = append(shape, "_")
+		}
+	}
+	return strings.Join(shape, " ")
+}
+
+func (f *fingerprinter) frames(frames []runtime.Frame) {
+	for _, frame := range frames {
+		if stack.IsLibrary(frame.Function) {
+			continue
+		}
+		if f.lines {
+			f.add("at %s %s:%d", frame.Function, filepath.Base(frame.File), frame.Line)
+		} else {
+			f.add("at %s %s", frame.Function, filepath.Base(frame.File))
+		}
+	}
+}
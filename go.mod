@@ -1,5 +1,5 @@
 module github.com/gregwebs/try
 
-go 1.18
+go 1.21
 
 require github.com/gregwebs/errors v0.13.0
@@ -1,14 +1,24 @@
 package try
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
+	"path/filepath"
 	"runtime"
 
-	"github.com/gregwebs/errors"
+	"github.com/gregwebs/try/internal/annotate"
+	"github.com/gregwebs/try/internal/crash"
+	"github.com/gregwebs/try/internal/kind"
+	"github.com/gregwebs/try/internal/kv"
+	"github.com/gregwebs/try/internal/observe"
+	"github.com/gregwebs/try/internal/panics"
+	"github.com/gregwebs/try/internal/span"
+	"github.com/gregwebs/try/internal/stack"
 )
 
-var AnnotatePanics bool = true
-
 // Handle handles any errors with a given handler function
 //
 // Every function using Try*/Check* must defer a Handle* function.
@@ -20,6 +30,8 @@ var AnnotatePanics bool = true
 // errors, there are a CatchXxxx functions. The handler is called only when err
 // != nil. There is no limit how many Handle functions can be added to defer
 // stack. They all are called if an error has occurred and they are in deferred.
+// A context error is passed through unchanged: the annotating handlers such as Handlew
+// record the function that observed a context cancellation.
 // This function will convert panics to errors
 func Handle(err *error, handlerFn func(err error) error) {
 	// We need to call `recover` here because of how it works with defer.
@@ -39,6 +51,75 @@ func HandleCleanup(err *error, handlerFn func()) {
 	})
 }
 
+// HandleClose closes c and merges the error from Close into the returned error.
+// Must be used as a `defer`.
+//
+//	w, err := os.Create(dst)
+//	try.Check(err)
+//	defer handle.Close(&err, w)
+//
+// If there is no other error, the error from Close becomes the returned error.
+// Otherwise the error from Close is attached to the error as a secondary error:
+// the message and errors.Is for the primary error are preserved.
+// Like HandleCleanup, HandleClose is also run when there is a panic.
+// This function will convert panics to errors
+func HandleClose(err *error, c io.Closer) {
+	// We need to call `recover` here because of how it works with defer.
+	r := recover()
+	release(r, err, "close", c.Close, c.Close)
+}
+
+// release finishes with a resource for Close and Tx.
+// When there is an error or a panic, onError is called and its error is attached to the error as a secondary error.
+// Otherwise onSuccess is called and its error becomes the returned error.
+func release(r any, err *error, op string, onError, onSuccess func() error) {
+	done := false
+	defer func() {
+		// handleRecover rethrows a panic without calling the handler when AnnotatePanics is disabled
+		if !done {
+			_ = onError()
+		}
+	}()
+	handleRecover(r, err, func(err error) error {
+		done = true
+		return withSecondary(err, op, onError())
+	})
+	// A panic is rethrown by handleRecover, so there was no panic if this is reached
+	if !done {
+		done = true
+		if releaseErr := onSuccess(); releaseErr != nil {
+			if Config.AddStackTrace() {
+				// Skip release and Close or Tx so that the stack trace starts at the function that deferred them
+				releaseErr = stack.Add(releaseErr, 2)
+			}
+			*err = releaseErr
+		}
+	}
+}
+
+// Transaction is satisfied by *sql.Tx
+type Transaction interface {
+	Commit() error
+	Rollback() error
+}
+
+// HandleTx commits or rolls back a transaction.
+// Must be used as a `defer`.
+//
+//	tx, err := db.BeginTx(ctx, nil)
+//	try.Check(err)
+//	defer handle.Tx(&err, tx)
+//
+// The transaction is committed if there is no error and the error from Commit becomes the returned error.
+// The transaction is rolled back if there is an error or a panic.
+// An error from Rollback is attached to the error as a secondary error.
+// This function will convert panics to errors
+func HandleTx(err *error, tx Transaction) {
+	// We need to call `recover` here because of how it works with defer.
+	r := recover()
+	release(r, err, "rollback", tx.Rollback, tx.Commit)
+}
+
 // Handlef is for handling errors by annotating them with a format string.
 // Must be used as a `defer`.
 // It appends ": %v" to the format string
@@ -46,15 +127,13 @@ func HandleCleanup(err *error, handlerFn func()) {
 func Handlef(err *error, prefix string, args ...any) {
 	// We need to call `recover` here because of how it works with defer.
 	r := recover()
-	if AddStackTrace {
+	if Config.AddStackTrace() {
 		handleRecover(r, err, func(err error) error {
-			args = append(args, err)
-			return errors.Errorf(prefix+": %v", args...)
+			return stack.Annotated(annotate.Errorf(err, prefix, args...), 0)
 		})
 	} else {
 		handleRecover(r, err, func(err error) error {
-			args = append(args, err)
-			return fmt.Errorf(prefix+": %v", args...)
+			return annotate.Errorf(err, prefix, args...)
 		})
 	}
 }
@@ -62,37 +141,176 @@ func Handlef(err *error, prefix string, args ...any) {
 // Handlew is for annotating an error.
 // Must be used as a `defer`.
 // It wraps the error with a message, similar to using "%w" in a format string
+//
+// When the error is a context cancellation, the first annotating Handle* function
+// records the function that observed it in the "ctx_observed_by" field.
+// The elapsed time of the ContextError thrown by CheckCtx is only known
+// when the context was created with WithStart. The time past the deadline is given for a context with a deadline.
 // This function will convert panics to errors
 func Handlew(err *error, prefix string, args ...any) {
 	// We need to call `recover` here because of how it works with defer.
 	r := recover()
-	if AddStackTrace {
+	if Config.AddStackTrace() {
 		handleRecover(r, err, func(err error) error {
-			return errors.Wrapf(err, prefix, args...)
+			return stack.Wrap(observeCancellation(err), fmt.Sprintf(prefix, args...), 0)
 		})
 	} else {
 		handleRecover(r, err, func(err error) error {
-			args = append(args, err)
-			return fmt.Errorf(prefix+": %w", args...)
+			return fmt.Errorf(prefix+": %w", append(append([]any(nil), args...), observeCancellation(err))...)
 		})
 	}
 }
 
+// Handlekv is for annotating an error with a message and structured fields.
+// Must be used as a `defer`.
+// The fields are given as alternating keys and values, the same as log/slog.
+// They can be retrieved from anywhere in the error chain with try.Fields
+// A context cancellation is recorded in the same way as Handlew.
+// This function will convert panics to errors
+func Handlekv(err *error, msg string, kvs ...any) {
+	// We need to call `recover` here because of how it works with defer.
+	r := recover()
+	handleRecover(r, err, func(err error) error {
+		err = kv.Wrap(observeCancellation(err), msg, kvs...)
+		if Config.AddStackTrace() {
+			err = stack.Annotated(err, 0)
+		}
+		return err
+	})
+}
+
+// HandleKind is for tagging an error with a Kind such as try.NotFound.
+// Must be used as a `defer`.
+// The Kind can be read with try.KindOf and errors.Is(err, try.NotFound) will hold.
+// This function will convert panics to errors
+func HandleKind(err *error, k kind.Kind) {
+	// We need to call `recover` here because of how it works with defer.
+	r := recover()
+	handleRecover(r, err, func(err error) error {
+		return kind.Wrap(err, k)
+	})
+}
+
+// HandleLog is for logging an error with log/slog.
+// Must be used as a `defer`.
+// The error is logged under the key "error" and is returned unchanged.
+// Errors created by this library will be logged with their annotations, fields, and a stack summary.
+// This function will convert panics to errors
+func HandleLog(err *error, logger *slog.Logger, level slog.Level, msg string) {
+	// We need to call `recover` here because of how it works with defer.
+	r := recover()
+	handleRecover(r, err, func(err error) error {
+		logger.Log(context.Background(), level, msg, slog.Any("error", stack.Loggable(err)))
+		return err
+	})
+}
+
+// TraceSpan is the part of a tracing span that HandleSpan uses.
+// An OpenTelemetry span satisfies it through a small adapter that converts the status and the attributes.
+//
+//	type TraceSpan interface {
+//		RecordError(err error, attrs ...slog.Attr)
+//		SetStatus(code SpanStatus, msg string)
+//	}
+type TraceSpan = span.Span
+
+// SpanStatus is the status of a span. The values are the same as OpenTelemetry status codes.
+type SpanStatus = span.Status
+
+const (
+	SpanStatusUnset = span.StatusUnset
+	SpanStatusError = span.StatusError
+	SpanStatusOK    = span.StatusOK
+)
+
+// SpanRecorder is an in-memory TraceSpan for tests.
+// Its Errors method gives the recorded errors and its Status method gives the status.
+type SpanRecorder = span.Recorder
+
+// RecordedError is an error recorded by a SpanRecorder
+type RecordedError = span.RecordedError
+
+// HandleSpan is for recording an error to a tracing span.
+// Must be used as a `defer`.
+//
+//	ctx, s := tracer.Start(ctx, "load")
+//	defer s.End()
+//	defer handle.Span(&err, otelSpan{s})
+//
+// The error is recorded with the attributes "exception.message" and "exception.stacktrace" when there is a stack trace.
+// A panic is also recorded with the attributes "panic.value" and "panic.type" before it is rethrown.
+// The stack trace of a panic starts at the place where the panic happened.
+// The status of the span is set to SpanStatusError.
+// This function will convert panics to errors
+func HandleSpan(err *error, s TraceSpan) {
+	// We need to call `recover` here because of how it works with defer.
+	r := recover()
+	handleRecover(r, err, func(err error) error {
+		span.Record(s, err, r)
+		return nil
+	})
+}
+
 // Annotate panics with information from Handle* functions.
 // A dummy error will be created with the Panic as a string
-type PanicAnnotated struct {
-	Panic any
-	// This error is the same as the panic
-	// It allows functions that expect to annotate an error
-	// to provide their annotation
-	Err error
+//
+//	type PanicAnnotated struct {
+//		Panic any
+//		// This error is the same as the panic
+//		// It allows functions that expect to annotate an error
+//		// to provide their annotation
+//		Err error
+//		// PanicStack is the stack trace of the place where the panic happened.
+//		// It is recorded when the panic is first recovered.
+//		PanicStack []runtime.Frame
+//	}
+//
+// Formatting with %+v prints the panic and the stack trace of the place where the panic happened,
+// followed by the annotations.
+// Unwrap gives Err and the panic if it is an error, so errors.Is and errors.As find both.
+type PanicAnnotated = panics.Annotated
+
+// PanicValue gives the panic value of a PanicAnnotated in the error chain.
+// It returns false if the error is not from a panic.
+func PanicValue(err error) (any, bool) {
+	var panicked PanicAnnotated
+	if !errors.As(err, &panicked) {
+		return nil, false
+	}
+	return panicked.Panic, true
 }
 
-func (p PanicAnnotated) Error() string {
-	// %+v should be available to get a stack,
-	// but we shouldn't need it because this
-	// should get thrown in a stack trace
-	return fmt.Sprintf("%+v, %v", p.Panic, p.Err)
+// RecoverEvent is given to the OnRecover hooks
+//
+//	type RecoverEvent struct {
+//		Kind RecoverKind
+//		// Err is the error after the handler was applied
+//		Err error
+//		// Panic is the panic value for RecoverPanic and RecoverAnnotated
+//		Panic any
+//		// Site is the first function outside of this library on the stack.
+//		// For a panic this is where the panic happened.
+//		Site runtime.Frame
+//	}
+type RecoverEvent = observe.RecoverEvent
+
+// RecoverKind is the kind of value that a Handle* function recovered
+type RecoverKind = observe.RecoverKind
+
+const (
+	// RecoverError is an error thrown by Check
+	RecoverError = observe.RecoverError
+	// RecoverPanic is a panic that was not recovered before
+	RecoverPanic = observe.RecoverPanic
+	// RecoverAnnotated is a PanicAnnotated that was rethrown by another Handle* function
+	RecoverAnnotated = observe.RecoverAnnotated
+)
+
+// OnRecover adds a hook that is called when a Handle* function recovers an error or a panic.
+// Hooks are called in the order they were added, before the panic of a PanicAnnotated continues.
+// Hooks must not panic. The returned function removes the hook.
+func OnRecover(fn func(RecoverEvent)) (remove func()) {
+	return observe.Recover.Add(fn)
 }
 
 // This function will convert panics to errors
@@ -102,11 +320,18 @@ func handleRecover(r any, err *error, handlerFn func(err error) error) {
 	// Otherwise panic again.
 	// Panic again with PanicAnnotated so that errors can be annotated
 	var panicked *PanicAnnotated
+	annotatePanics := Config.AnnotatePanics()
+
+	// http.ErrAbortHandler is an error, but net/http needs it to be re-panicked untouched.
+	// It is registered by tryhttp so that this package does not import net/http.
+	if panics.IsPassThrough(r) {
+		panic(r)
+	}
 
 	switch r := r.(type) {
 	case PanicAnnotated:
-		if !AnnotatePanics {
-			// This case isn't possible unless this flag
+		if !annotatePanics {
+			// This case isn't possible unless this setting
 			// is changed while the program is running
 			panic(r)
 		}
@@ -116,17 +341,18 @@ func handleRecover(r any, err *error, handlerFn func(err error) error) {
 
 	case runtime.Error:
 		// A Go panic
-		if !AnnotatePanics {
+		if !annotatePanics {
 			panic(r)
 		}
 
 		// Rethrow the panic, but first allow it to be annotated by attaching an error
 		if *err == nil {
 			// Convert to an error that has the stack trace
-			*err = errors.New(fmt.Sprintf("%+v", r))
+			*err = stack.New(fmt.Errorf("%+v", r), 0)
 		}
 		panicked = &PanicAnnotated{
-			Panic: r,
+			Panic:      r,
+			PanicStack: panics.Stack(0),
 		}
 	case error:
 		// try.Check or try.Try threw an error
@@ -141,17 +367,18 @@ func handleRecover(r any, err *error, handlerFn func(err error) error) {
 
 	default:
 		// A Go panic
-		if !AnnotatePanics {
+		if !annotatePanics {
 			panic(r)
 		}
 
 		// Rethrow the panic, but first allow it to be annotated by attaching an error
 		if *err == nil {
 			// Convert to an error that has the stack trace
-			*err = errors.New(fmt.Sprintf("%+v", r))
+			*err = stack.New(fmt.Errorf("%+v", r), 0)
 		}
 		panicked = &PanicAnnotated{
-			Panic: r,
+			Panic:      r,
+			PanicStack: panics.Stack(0),
 		}
 	}
 
@@ -161,6 +388,19 @@ func handleRecover(r any, err *error, handlerFn func(err error) error) {
 		}
 	}
 
+	if r != nil && observe.Recover.Active() {
+		event := RecoverEvent{Kind: RecoverError, Err: *err}
+		if panicked != nil {
+			event.Kind = RecoverPanic
+			if _, ok := r.(PanicAnnotated); ok {
+				event.Kind = RecoverAnnotated
+			}
+			event.Panic = panicked.Panic
+		}
+		event.Site, _ = stack.Caller(1)
+		observe.Recover.Call(event)
+	}
+
 	if panicked != nil {
 		if *err != nil {
 			panicked.Err = *err
@@ -169,6 +409,68 @@ func handleRecover(r any, err *error, handlerFn func(err error) error) {
 	}
 }
 
+// withSecondary attaches an error that happened while handling the primary error.
+func withSecondary(primary error, op string, secondary error) error {
+	if secondary == nil {
+		return primary
+	}
+	return &secondaryError{primary: primary, op: op, secondary: secondary}
+}
+
+// secondaryError has the message of the primary error followed by the secondary error.
+// errors.Is and errors.As will find both errors.
+type secondaryError struct {
+	primary   error
+	op        string
+	secondary error
+}
+
+func (e *secondaryError) Error() string {
+	return fmt.Sprintf("%v (%s: %v)", e.primary, e.op, e.secondary)
+}
+
+func (e *secondaryError) Unwrap() []error { return []error{e.primary, e.secondary} }
+
+// Errors satisfies errors.ErrorGroup from github.com/gregwebs/errors
+func (e *secondaryError) Errors() []error { return e.Unwrap() }
+
+// HasStack tells whether the primary error has a stack trace
+func (e *secondaryError) HasStack() bool { return stack.Has(e.primary) }
+
+func (e *secondaryError) Format(s fmt.State, verb rune) {
+	switch verb {
+	case 'v':
+		if s.Flag('+') {
+			fmt.Fprintf(s, "%+v\n%s: %+v", e.primary, e.op, e.secondary)
+			return
+		}
+		fallthrough
+	case 's':
+		io.WriteString(s, e.Error())
+	case 'q':
+		fmt.Fprintf(s, "%q", e.Error())
+	}
+}
+
+// observedByKey is the field that records the handler that observed a context cancellation
+const observedByKey = "ctx_observed_by"
+
+// observeCancellation adds a field to a context error giving the function of the first handler that observed it.
+// It is only used by the handlers that annotate the error: pass-through handlers such as Handle leave the error unchanged.
+func observeCancellation(err error) error {
+	if !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	if _, observed := kv.Fields(err)[observedByKey]; observed {
+		return err
+	}
+	frame, ok := stack.Caller(1)
+	if !ok {
+		return err
+	}
+	return kv.Wrap(err, "", observedByKey, fmt.Sprintf("%s %s:%d", frame.Function, filepath.Base(frame.File), frame.Line))
+}
+
 // CatchAll can be used in a function that does not return an error.
 // Must be used with defer
 // Converts a panic to an error
@@ -181,13 +483,79 @@ func CatchAll(handlerFn func(error)) {
 	})
 }
 
+// Reporter reports a crash recovered by Report or CatchReport.
+// FileReporter is a Reporter.
+//
+//	type Reporter interface {
+//		Report(c *Crash) error
+//	}
+type Reporter = crash.Reporter
+
+// Crash is a report of an error or a panic that was recovered by Report or CatchReport.
+// It has the error chain, the panic value, the stack trace from the place of the panic,
+// the stack traces of all goroutines, build information, the hostname, and the time.
+type Crash = crash.Crash
+
+// FileReporter writes each crash as a JSON file in a directory.
+// Its MaxReports field limits the number of reports that are kept: the oldest reports are removed.
+type FileReporter = crash.FileReporter
+
+// NewFileReporter creates a FileReporter that keeps at most maxReports reports in dir.
+// 0 means no limit.
+func NewFileReporter(dir string, maxReports int) *FileReporter {
+	return crash.NewFileReporter(dir, maxReports)
+}
+
+// Report can be used in a function that does not return an error, such as main or the start of a goroutine.
+// Must be used with defer
+//
+//	defer handle.Report(handle.NewFileReporter("/var/crash/myapp", 100))
+//
+// Report gives panics and errors thrown by try.Check to the reporter as a Crash and then panics again,
+// so the program still crashes with exit status 2.
+// Use CatchReport to stop the panic after it is reported.
+// If the reporter fails, the crash is printed to standard error instead.
+func Report(reporter Reporter) {
+	r := recover()
+	crash.Report(reporter, r)
+	if r != nil {
+		panic(r)
+	}
+}
+
+// CatchReport can be used in a function that does not return an error, such as the start of a goroutine.
+// Must be used with defer
+//
+// CatchReport stops panics and errors thrown by try.Check and gives a Crash to the reporter.
+// If the reporter fails, the crash is printed to standard error instead.
+func CatchReport(reporter Reporter) {
+	// We need to call `recover` here because of how it works with defer.
+	r := recover()
+	crash.Report(reporter, r)
+}
+
 // CatchHandlePanic can be used in a function that does not return an error
 // Must be used with defer
 //
 // CatchHandlePanic stops panics and gives the panic to the panicHandler
 // It uses ErrorFromRecover to extract errors and give them to the errorHandler
+// A PanicAnnotated from a Handle* function is given to the errorHandler: use PanicValue to tell it apart.
 func CatchHandlePanic(errorHandler func(error), panicHandler func(v any)) {
+	// We need to call `recover` here because of how it works with defer.
+	r := recover()
+	catchRecovered(r, errorHandler, panicHandler)
+}
+
+// CatchError can be used in a function that does not return an error
+// Must be used with defer
+// Does not handle panics
+func CatchError(errorHandler func(error)) {
+	// We need to call `recover` here because of how it works with defer.
 	r := recover()
+	catchRecovered(r, errorHandler, nil)
+}
+
+func catchRecovered(r any, errorHandler func(error), panicHandler func(v any)) {
 	if r == nil {
 		return
 	}
@@ -202,17 +570,13 @@ func CatchHandlePanic(errorHandler func(error), panicHandler func(v any)) {
 	}
 }
 
-// CatchError can be used in a function that does not return an error
-// Must be used with defer
-// Does not handle panics
-func CatchError(errorHandler func(error)) {
-	CatchHandlePanic(errorHandler, nil)
-}
-
 // ErrorFromRecover extracts a non-runtime error from the recovery object
+// A PanicAnnotated is returned as an error that carries the panic: use PanicValue to tell it apart.
 // Otherwise it returns nil
 func ErrorFromRecover(r any) error {
 	switch r := r.(type) {
+	case PanicAnnotated:
+		return r
 	case runtime.Error:
 		return nil
 	case error:
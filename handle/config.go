@@ -0,0 +1,10 @@
+package handle
+
+import "github.com/gregwebs/try/internal/config"
+
+// Config holds the settings for the try and handle packages.
+// It is the same value as try.Config.
+//
+// This is the only declaration in this package that is not copied to the top-level try package,
+// which gets its Config from try/try.go.
+var Config = config.Global
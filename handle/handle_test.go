@@ -1,17 +1,36 @@
 package handle_test
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
+	"net/http"
 	"os"
+	"runtime"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/gregwebs/try/assert"
 	"github.com/gregwebs/try/handle"
 	"github.com/gregwebs/try/try"
 )
 
+// TestMain runs the tests with the default settings rather than the TRY_* environment variables
+func TestMain(m *testing.M) {
+	try.Config.Override(func(c *try.Settings) {
+		c.SetAddStackTrace(true)
+		c.SetLazyStack(false)
+		c.SetStackDepth(32)
+		c.SetAnnotatePanics(true)
+	})
+	os.Exit(m.Run())
+}
+
 type zeroStruct struct{}
 
 func TestZero(t *testing.T) {
@@ -150,6 +169,760 @@ func TestTry_noError(t *testing.T) {
 	try.Check(err)
 }
 
+func TestCheck1_values(t *testing.T) {
+	s := try.Check1(noThrow())
+	assert.Equal(s, "test")
+	s1, s2 := try.Check2(twoStrNoThrow())
+	assert.Equal(s1+s2, "testtest")
+	b, i, s := try.Check3(boolIntStrNoThrow())
+	assert.That(b && i == 1 && s == "test", "Check3 values")
+	i, s = try.Checkw2(intStrNoThrow())("not thrown")
+	assert.That(i == 1 && s == "test", "Checkw2 values")
+}
+
+func TestCheck1_Error(t *testing.T) {
+	f := func() (err error) {
+		defer handle.Do(&err, nil)
+		_ = try.Checkw1(throw())("annotated %d", 1)
+		t.Fail() // If everything works we are never here
+		return nil
+	}
+	err := f()
+	assert.Equal(err.Error(), "annotated 1: this is an ERROR")
+}
+
+func TestTry_handlers(t *testing.T) {
+	cleaned := false
+	f := func() (err error) {
+		defer handle.Do(&err, nil)
+		_, _ = try.Try2(twoStrNoThrow())(try.Fmtw("not thrown"))
+		_ = try.Try1(throw())(try.Cleanup(func() { cleaned = true }), try.Fmtw("try %d", 1))
+		t.Fail() // If everything works we are never here
+		return nil
+	}
+	err := f()
+	assert.That(cleaned, "cleanup handler was not called")
+	assert.Equal(err.Error(), "try 1: this is an ERROR")
+}
+
+func TestFmtw_reused(t *testing.T) {
+	wrap := try.Fmtw("read %s", "config")
+	assert.Equal(wrap(io.EOF).Error(), "read config: EOF")
+	assert.Equal(wrap(io.EOF).Error(), "read config: EOF")
+
+	args := make([]any, 1, 4)
+	args[0] = "config"
+	annotate := try.Fmtf("read %s", args...)
+	assert.Equal(annotate(io.EOF).Error(), "read config: EOF")
+	assert.Equal(annotate(io.ErrUnexpectedEOF).Error(), "read config: unexpected EOF")
+	assert.SLen(args, 1)
+	assert.Equal(args[:2][1], nil)
+}
+
+func TestCheckIgnore(t *testing.T) {
+	assert.That(!try.CheckIgnore(nil, io.EOF), "nil is not ignored")
+	assert.That(try.CheckIgnore(fmt.Errorf("read: %w", io.EOF), io.EOF), "wrapped EOF is ignored")
+
+	f := func() (err error) {
+		defer handle.Do(&err, nil)
+		try.CheckIgnore(io.ErrUnexpectedEOF, io.EOF)
+		t.Fail() // If everything works we are never here
+		return nil
+	}
+	err := f()
+	assert.That(errors.Is(err, io.ErrUnexpectedEOF), "other errors are thrown")
+}
+
+func TestOr(t *testing.T) {
+	assert.Equal(try.Or(1, nil, 2), 1)
+	assert.Equal(try.Or(1, io.EOF, 2), 2)
+	assert.Equal(try.Or(1, io.EOF, 2, io.EOF), 2)
+	assert.Equal(try.OrElse(1, io.EOF, func(err error) int { return 3 }, io.EOF), 3)
+
+	f := func() (err error) {
+		defer handle.Do(&err, nil)
+		try.OrElse(1, io.ErrUnexpectedEOF, func(err error) int { return 3 }, io.EOF)
+		t.Fail() // If everything works we are never here
+		return nil
+	}
+	err := f()
+	assert.That(errors.Is(err, io.ErrUnexpectedEOF), "other errors are thrown")
+}
+
+var errNotFound = errors.New("not found")
+
+func TestHandlerCombinators(t *testing.T) {
+	lookup := func(thrown error) (err error) {
+		defer handle.Do(&err, try.Chain(
+			try.Replace(io.EOF, errNotFound),
+			try.IfAs(func(err *os.PathError) error {
+				return fmt.Errorf("path %s: %w", err.Path, err)
+			}),
+			try.When(func(err error) bool { return errors.Is(err, io.ErrClosedPipe) }, try.Mark(errNotFound)),
+		))
+		try.Check(thrown)
+		return nil
+	}
+
+	err := lookup(io.EOF)
+	assert.That(err == errNotFound, "replace")
+
+	err = lookup(&os.PathError{Op: "open", Path: "/x", Err: os.ErrNotExist})
+	assert.Equal(err.Error(), "path /x: open /x: file does not exist")
+
+	err = lookup(io.ErrClosedPipe)
+	assert.That(errors.Is(err, errNotFound), "mark")
+	assert.That(errors.Is(err, io.ErrClosedPipe), "mark keeps the original error")
+	assert.Equal(err.Error(), io.ErrClosedPipe.Error())
+
+	err = lookup(io.ErrUnexpectedEOF)
+	assert.That(errors.Is(err, io.ErrUnexpectedEOF), "unmatched errors are unchanged")
+	assert.That(!errors.Is(err, errNotFound), "unmatched errors are not marked")
+}
+
+func TestIfIs_Check(t *testing.T) {
+	f := func() (err error) {
+		defer handle.Do(&err, nil)
+		try.Check(io.EOF, try.IfIs(io.EOF, try.Fmtw("end")), try.IfIs(errNotFound, try.Fmtw("not found")))
+		return nil
+	}
+	assert.Equal(f().Error(), "end: EOF")
+}
+
+func TestWrapKV_Fields(t *testing.T) {
+	f := func(user int) (err error) {
+		defer handle.WrapKV(&err, "handle request", "request", "r1", "user", user)
+		try.Checkkv(io.EOF, "read config", "path", "/etc/app", "user", 0, "orphan")
+		return nil
+	}
+	err := f(7)
+	assert.Equal(err.Error(), "handle request: read config: EOF")
+	assert.That(errors.Is(err, io.EOF), "fields keep the chain")
+
+	fields := try.Fields(err)
+	assert.MLen(fields, 4)
+	assert.That(fields["request"] == "r1", "request field")
+	assert.That(fields["path"] == "/etc/app", "path field")
+	assert.That(fields["user"] == 7, "the outermost value is used")
+	assert.That(fields["!BADKEY"] == "orphan", "a value without a key")
+
+	assert.MLen(try.Fields(io.EOF), 0)
+}
+
+func TestLog(t *testing.T) {
+	defer try.Config.Override(func(c *try.Settings) {
+		c.SetAddStackTrace(true)
+	})()
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	f := func() (err error) {
+		defer handle.Log(&err, logger, slog.LevelError, "request failed")
+		defer handle.Wrap(&err, "handle request")
+		try.Checkkv(io.EOF, "read body", "request", "r1")
+		return nil
+	}
+	err := f()
+	assert.That(errors.Is(err, io.EOF), "the error is passed through")
+
+	var record struct {
+		Msg   string
+		Error struct {
+			Msg         string
+			Annotations []string
+			Fields      map[string]any
+			Stack       []string
+		}
+	}
+	assert.NoError(json.Unmarshal(buf.Bytes(), &record))
+	assert.Equal(record.Msg, "request failed")
+	assert.Equal(record.Error.Msg, "handle request: read body: EOF")
+	assert.SLen(record.Error.Annotations, 2)
+	assert.Equal(record.Error.Annotations[0], "handle request")
+	assert.Equal(record.Error.Annotations[1], "read body")
+	assert.That(record.Error.Fields["request"] == "r1", "fields")
+	assert.SNotEmpty(record.Error.Stack)
+}
+
+func TestWrap_LogValue(t *testing.T) {
+	defer try.Config.Override(func(c *try.Settings) {
+		c.SetAddStackTrace(true)
+	})()
+	wrapped := func() (err error) {
+		defer handle.Wrap(&err, "handle request")
+		try.Check(io.EOF)
+		return nil
+	}
+	wrappedKV := func() (err error) {
+		defer handle.WrapKV(&err, "handle request", "request", "r1")
+		try.Check(io.EOF)
+		return nil
+	}
+	for _, f := range []func() error{wrapped, wrappedKV} {
+		err := f()
+		_, ok := err.(slog.LogValuer)
+		assert.That(ok, "the annotated error is a slog.LogValuer")
+		assert.That(strings.Contains(fmt.Sprintf("%+v", err), "handle_test.go"), "the stack is printed")
+
+		var buf bytes.Buffer
+		slog.New(slog.NewJSONHandler(&buf, nil)).Error("failed", "error", err)
+		var record struct {
+			Error struct {
+				Msg         string
+				Annotations []string
+				Stack       []string
+			}
+		}
+		assert.NoError(json.Unmarshal(buf.Bytes(), &record))
+		assert.Equal(record.Error.Msg, "handle request: EOF")
+		assert.SLen(record.Error.Annotations, 1)
+		assert.Equal(record.Error.Annotations[0], "handle request")
+		assert.SNotEmpty(record.Error.Stack)
+		assert.That(strings.HasPrefix(record.Error.Stack[0], "github.com/gregwebs/try/handle_test.TestWrap_LogValue.func"), "the library frames are skipped: "+record.Error.Stack[0])
+	}
+	var buf bytes.Buffer
+	slog.New(slog.NewJSONHandler(&buf, nil)).Error("failed", "error", wrappedKV())
+	assert.That(strings.Contains(buf.String(), `"fields":{"request":"r1"}`), buf.String())
+}
+
+func TestPanicAnnotated_LogValue(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	func() {
+		defer func() {
+			r := recover()
+			logger.Error("panic", "panic", r)
+		}()
+		func() (err error) {
+			defer handle.Wrap(&err, "annotated")
+			panic("boom")
+		}()
+	}()
+	assert.That(strings.Contains(buf.String(), `"panic":{"panic":"boom","error":{"msg":"annotated: boom"`), buf.String())
+
+	var record struct {
+		Panic struct {
+			Error struct {
+				Stack []string
+			}
+		}
+	}
+	assert.NoError(json.Unmarshal(buf.Bytes(), &record))
+	assert.SNotEmpty(record.Panic.Error.Stack)
+	assert.That(strings.HasPrefix(record.Panic.Error.Stack[0], "github.com/gregwebs/try/handle_test.TestPanicAnnotated_LogValue.func1.2 "), "the stack starts at the panic: "+record.Panic.Error.Stack[0])
+}
+
+type node struct{ next *node }
+
+func derefNil(n *node) *node {
+	return n.next
+}
+
+func TestPanicAnnotated_PanicStack(t *testing.T) {
+	var panicked handle.PanicAnnotated
+	func() {
+		defer func() {
+			panicked = recover().(handle.PanicAnnotated)
+		}()
+		func() (err error) {
+			defer handle.Wrap(&err, "outer")
+			func() (err error) {
+				defer handle.Wrap(&err, "inner")
+				derefNil(nil)
+				return nil
+			}()
+			return nil
+		}()
+	}()
+	assert.That(len(panicked.PanicStack) > 0, "the panic stack is recorded")
+	assert.Equal(panicked.PanicStack[0].Function, "github.com/gregwebs/try/handle_test.derefNil")
+	assert.That(strings.Contains(panicked.PanicStack[1].Function, "TestPanicAnnotated_PanicStack.func"), panicked.PanicStack[1].Function)
+
+	printed := fmt.Sprintf("%+v", panicked)
+	lines := strings.Split(printed, "\n")
+	assert.That(strings.HasPrefix(lines[0], "panic: runtime error: invalid memory address or nil pointer dereference"), lines[0])
+	assert.Equal(lines[1], "github.com/gregwebs/try/handle_test.derefNil")
+	annotations := strings.Index(printed, "outer")
+	assert.That(annotations > strings.Index(printed, "handle_test.derefNil"), "the panic site is printed before the annotations: "+printed)
+	assert.Equal(fmt.Sprintf("%v", panicked), panicked.Error())
+}
+
+var errSentinel = errors.New("sentinel")
+
+func TestPanicAnnotated_Unwrap(t *testing.T) {
+	var recovered error
+	func() {
+		defer handle.CatchHandlePanic(func(err error) {
+			recovered = err
+		}, func(v any) {
+			t.Errorf("a PanicAnnotated is given to the error handler: %v", v)
+		})
+		func() (err error) {
+			defer handle.Wrap(&err, "annotated")
+			derefNil(nil)
+			return nil
+		}()
+	}()
+	assert.That(recovered != nil, "the error handler gets the PanicAnnotated")
+	_, ok := handle.ErrorFromRecover(recovered).(handle.PanicAnnotated)
+	assert.That(ok, "ErrorFromRecover gives the PanicAnnotated")
+
+	var caught error
+	func() {
+		defer handle.CatchError(func(err error) { caught = err })
+		func() (err error) {
+			defer handle.Wrap(&err, "annotated")
+			panic("boom")
+		}()
+	}()
+	value, ok := handle.PanicValue(caught)
+	assert.That(ok && value == "boom", "CatchError gives the PanicAnnotated to the error handler")
+	var runtimeErr runtime.Error
+	assert.That(errors.As(recovered, &runtimeErr), "the runtime.Error panic is found")
+	value, ok = handle.PanicValue(recovered)
+	assert.That(ok, "PanicValue finds the panic")
+	assert.That(value == runtimeErr, "PanicValue gives the panic value")
+
+	panicked := handle.PanicAnnotated{Panic: fmt.Errorf("wrapped: %w", errSentinel), Err: io.EOF}
+	assert.That(errors.Is(panicked, errSentinel), "the panic is unwrapped")
+	assert.That(errors.Is(panicked, io.EOF), "the annotations are unwrapped")
+	assert.SLen(handle.PanicAnnotated{Panic: "boom"}.Unwrap(), 0)
+
+	_, ok = handle.PanicValue(fmt.Errorf("thrown: %w", io.EOF))
+	assert.That(!ok, "an error is not a panic")
+	value, ok = handle.PanicValue(fmt.Errorf("logged: %w", handle.PanicAnnotated{Panic: "boom"}))
+	assert.That(ok && value == "boom", "a wrapped PanicAnnotated is found")
+}
+
+func TestConfig_shared(t *testing.T) {
+	assert.That(try.Config == handle.Config, "try and handle share the Config")
+	defer try.Config.Override(func(c *try.Settings) {
+		c.SetAddStackTrace(false)
+	})()
+
+	f := func() (err error) {
+		defer handle.Format(&err, "no stack")
+		try.Check(io.EOF)
+		return nil
+	}
+	err := f()
+	assert.Equal(fmt.Sprintf("%+v", err), "no stack: EOF")
+}
+
+func wrapDepth(depth int) (err error) {
+	defer handle.Wrap(&err, "depth %d", depth)
+	if depth == 0 {
+		try.Check(io.EOF)
+	}
+	try.Check(wrapDepth(depth - 1))
+	return nil
+}
+
+func TestStack_noDuplicates(t *testing.T) {
+	err := wrapDepth(3)
+	assert.Equal(err.Error(), "depth 3: depth 2: depth 1: depth 0: EOF")
+	stacks := strings.Count(fmt.Sprintf("%+v", err), "testing.tRunner")
+	assert.Equal(stacks, 1, "expected a single stack trace")
+}
+
+func TestStack_depth(t *testing.T) {
+	defer try.Config.Override(func(c *try.Settings) {
+		c.SetAddStackTrace(true)
+		c.SetStackDepth(2)
+	})()
+	err := wrapDepth(0)
+	frames := strings.Count(fmt.Sprintf("%+v", err), "\n\t")
+	assert.Equal(frames, 2)
+}
+
+func TestStack_lazy(t *testing.T) {
+	defer try.Config.Override(func(c *try.Settings) {
+		c.SetAddStackTrace(true)
+		c.SetLazyStack(true)
+	})()
+	err := wrapDepth(0)
+	printed := fmt.Sprintf("%+v", err)
+	assert.That(strings.Contains(printed, "handle_test.wrapDepth"), printed)
+}
+
+// firstFrame gives the function of the first frame of the stack trace printed with %+v
+func firstFrame(err error) string {
+	lines := strings.Split(fmt.Sprintf("%+v", err), "\n")
+	for i := 1; i < len(lines); i++ {
+		if strings.HasPrefix(lines[i], "\t") {
+			return lines[i-1]
+		}
+	}
+	return ""
+}
+
+func checkHelper(err error) {
+	try.Helper()
+	try.Checkw(err, "helper")
+}
+
+func checkSkip(err error) {
+	try.CheckSkip(err, 1, try.Fmtw("skip"))
+}
+
+func wrapHelper(err error) (rerr error) {
+	try.Helper()
+	defer handle.Wrap(&rerr, "helper")
+	return err
+}
+
+func TestStack_helper(t *testing.T) {
+	err := func() (err error) {
+		defer handle.Do(&err, nil)
+		checkHelper(io.EOF)
+		return nil
+	}()
+	assert.Equal(err.Error(), "helper: EOF")
+	assert.That(strings.HasSuffix(firstFrame(err), "TestStack_helper.func1"), firstFrame(err))
+	assert.That(!strings.Contains(fmt.Sprintf("%+v", err), "checkHelper"), "helpers are omitted")
+
+	err = func() (err error) {
+		defer handle.Do(&err, nil)
+		checkSkip(io.EOF)
+		return nil
+	}()
+	assert.Equal(err.Error(), "skip: EOF")
+	assert.That(strings.HasSuffix(firstFrame(err), "TestStack_helper.func2"), firstFrame(err))
+
+	err = func() (err error) {
+		defer handle.Do(&err, nil)
+		try.Checkw(io.EOF, "checkw")
+		return nil
+	}()
+	assert.That(strings.HasSuffix(firstFrame(err), "TestStack_helper.func3"), "the stack starts at the caller of Checkw: "+firstFrame(err))
+
+	err = wrapHelper(io.EOF)
+	assert.Equal(err.Error(), "helper: EOF")
+	assert.That(!strings.Contains(fmt.Sprintf("%+v", err), "wrapHelper"), "helpers are omitted from Handlew")
+}
+
+var errShutdown = errors.New("shutdown")
+
+func cancelledWork(ctx context.Context) (err error) {
+	defer handle.Wrap(&err, "work")
+	try.CheckCtxw(ctx, "step %d", 2)
+	return nil
+}
+
+func TestCheckCtx(t *testing.T) {
+	try.CheckCtx(context.Background())
+
+	ctx, cancel := context.WithCancelCause(try.WithStart(context.Background()))
+	cancel(errShutdown)
+	f := func() (err error) {
+		defer handle.Wrap(&err, "request")
+		try.Check(cancelledWork(ctx))
+		return nil
+	}
+	err := f()
+	assert.That(errors.Is(err, context.Canceled), "context.Canceled")
+	assert.That(errors.Is(err, errShutdown), "the cause")
+	assert.That(strings.HasPrefix(err.Error(), "request: work: step 2: context canceled (cause: shutdown, elapsed: "), err.Error())
+
+	var ctxErr *try.ContextError
+	assert.That(errors.As(err, &ctxErr), "ContextError")
+	assert.That(ctxErr.Elapsed > 0, "elapsed")
+
+	observer, _ := try.Fields(err)["ctx_observed_by"].(string)
+	assert.That(strings.HasPrefix(observer, "github.com/gregwebs/try/handle_test.cancelledWork handle_test.go:"), observer)
+}
+
+func TestCheckCtx_passThrough(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := func() (err error) {
+		defer handle.Do(&err, nil)
+		defer handle.Cleanup(&err, func() {})
+		return ctx.Err()
+	}()
+	assert.That(err == context.Canceled, "pass-through handlers leave a context error unchanged")
+
+	err = func() (err error) {
+		defer handle.Wrap(&err, "outer")
+		defer handle.Wrap(&err, "inner")
+		return ctx.Err()
+	}()
+	assert.Equal(err.Error(), "outer: inner: context canceled")
+	observer, _ := try.Fields(err)["ctx_observed_by"].(string)
+	assert.That(strings.Contains(observer, "TestCheckCtx_passThrough.func2 "), observer)
+}
+
+func TestCheckCtx_deadline(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), -time.Second)
+	defer cancel()
+	err := cancelledWork(ctx)
+	assert.That(errors.Is(err, context.DeadlineExceeded), "context.DeadlineExceeded")
+	assert.That(strings.Contains(err.Error(), "context deadline exceeded (deadline: "), err.Error())
+	assert.That(strings.Contains(err.Error(), ", past deadline: "), "the timing is given without WithStart: "+err.Error())
+	var ctxErr *try.ContextError
+	assert.That(errors.As(err, &ctxErr), "ContextError")
+	assert.That(ctxErr.PastDeadline >= time.Second, ctxErr.PastDeadline.String())
+}
+
+type closer struct {
+	err    error
+	closed bool
+}
+
+func (c *closer) Close() error {
+	c.closed = true
+	return c.err
+}
+
+var errClose = errors.New("close failed")
+
+func TestClose(t *testing.T) {
+	use := func(c io.Closer, thrown error) (err error) {
+		defer handle.Close(&err, c)
+		try.Check(thrown)
+		return nil
+	}
+
+	c := &closer{}
+	assert.NoError(use(c, nil))
+	assert.That(c.closed, "closed")
+
+	c = &closer{err: errClose}
+	err := use(c, nil)
+	assert.That(errors.Is(err, errClose), "the close error is returned on success")
+	var stacked interface{ Frames() []runtime.Frame }
+	assert.That(errors.As(err, &stacked), "the close error has a stack trace")
+	assert.That(strings.HasPrefix(stacked.Frames()[0].Function, "github.com/gregwebs/try/handle_test.TestClose.func"), "the stack starts at the function that deferred Close: "+stacked.Frames()[0].Function)
+
+	c = &closer{err: errClose}
+	err = use(c, io.EOF)
+	assert.That(c.closed, "closed")
+	assert.Equal(err.Error(), "EOF (close: close failed)")
+	assert.That(errors.Is(err, io.EOF), "the primary error is kept")
+	assert.That(errors.Is(err, errClose), "the close error is attached")
+
+	c = &closer{}
+	err = use(c, io.EOF)
+	assert.Equal(err.Error(), "EOF")
+}
+
+func TestClose_panic(t *testing.T) {
+	c := &closer{err: errClose}
+	defer func() {
+		r := recover()
+		assert.That(c.closed, "closed during a panic")
+		panicked, ok := r.(handle.PanicAnnotated)
+		assert.That(ok, "the panic is annotated")
+		assert.That(errors.Is(panicked.Err, errClose), "the close error is attached to the panic")
+	}()
+	func() (err error) {
+		defer handle.Close(&err, c)
+		panic("boom")
+	}()
+}
+
+type tx struct {
+	commitErr   error
+	rollbackErr error
+	committed   bool
+	rolledBack  bool
+}
+
+func (tx *tx) Commit() error {
+	tx.committed = true
+	return tx.commitErr
+}
+
+func (tx *tx) Rollback() error {
+	tx.rolledBack = true
+	return tx.rollbackErr
+}
+
+var errRollback = errors.New("rollback failed")
+
+func TestTx(t *testing.T) {
+	update := func(tx handle.Transaction, thrown error) (err error) {
+		defer handle.Tx(&err, tx)
+		try.Check(thrown)
+		return nil
+	}
+
+	tx1 := &tx{}
+	assert.NoError(update(tx1, nil))
+	assert.That(tx1.committed && !tx1.rolledBack, "committed")
+
+	tx1 = &tx{commitErr: io.ErrClosedPipe}
+	err := update(tx1, nil)
+	assert.That(errors.Is(err, io.ErrClosedPipe), "the commit error is returned")
+	var stacked interface{ Frames() []runtime.Frame }
+	assert.That(errors.As(err, &stacked), "the commit error has a stack trace")
+	assert.That(strings.HasPrefix(stacked.Frames()[0].Function, "github.com/gregwebs/try/handle_test.TestTx.func"), "the stack starts at the function that deferred Tx: "+stacked.Frames()[0].Function)
+
+	tx1 = &tx{}
+	err = update(tx1, io.EOF)
+	assert.That(!tx1.committed && tx1.rolledBack, "rolled back")
+	assert.Equal(err.Error(), "EOF")
+
+	tx1 = &tx{rollbackErr: errRollback}
+	err = update(tx1, io.EOF)
+	assert.Equal(err.Error(), "EOF (rollback: rollback failed)")
+	assert.That(errors.Is(err, io.EOF) && errors.Is(err, errRollback), "both errors are kept")
+}
+
+func TestTx_panic(t *testing.T) {
+	tx1 := &tx{rollbackErr: errRollback}
+	defer func() {
+		r := recover()
+		assert.That(!tx1.committed && tx1.rolledBack, "rolled back during a panic")
+		panicked, ok := r.(handle.PanicAnnotated)
+		assert.That(ok, "the panic is annotated")
+		assert.That(errors.Is(panicked.Err, errRollback), "the rollback error is attached to the panic")
+	}()
+	func() (err error) {
+		defer handle.Tx(&err, tx1)
+		// The panic passes through another handler first as a PanicAnnotated
+		defer handle.Wrap(&err, "inner")
+		var b []byte
+		b[0] = 0
+		return nil
+	}()
+}
+
+func TestCloseTx_panicNotAnnotated(t *testing.T) {
+	defer try.Config.Override(func(c *try.Settings) {
+		c.SetAnnotatePanics(false)
+	})()
+	c := &closer{}
+	tx1 := &tx{}
+	func() {
+		defer func() {
+			assert.That(recover() == "boom", "the panic is rethrown as is")
+		}()
+		func() (err error) {
+			defer handle.Close(&err, c)
+			defer handle.Tx(&err, tx1)
+			panic("boom")
+		}()
+	}()
+	assert.That(c.closed, "closed during a panic that is not annotated")
+	assert.That(!tx1.committed && tx1.rolledBack, "rolled back during a panic that is not annotated")
+}
+
+var errUserNotFound = try.NewSentinel(try.NotFound, "user not found")
+
+func TestSpan(t *testing.T) {
+	var recorder handle.SpanRecorder
+	err := func() (err error) {
+		defer handle.Span(&err, &recorder)
+		return nil
+	}()
+	assert.NoError(err)
+	assert.SLen(recorder.Errors(), 0)
+	status, _ := recorder.Status()
+	assert.Equal(status, handle.SpanStatusUnset)
+
+	err = func() (err error) {
+		defer handle.Span(&err, &recorder)
+		try.Checkw(io.EOF, "read")
+		return nil
+	}()
+	assert.Equal(err.Error(), "read: EOF")
+	recorded := recorder.Errors()
+	assert.SLen(recorded, 1)
+	assert.That(errors.Is(recorded[0].Err, io.EOF), "the error is recorded")
+	assert.Equal(recorded[0].Attr("exception.message"), "read: EOF")
+	assert.That(strings.Contains(recorded[0].Attr("exception.stacktrace"), "handle_test.TestSpan"), recorded[0].Attr("exception.stacktrace"))
+	assert.Equal(recorded[0].Attr("panic.value"), "")
+	status, msg := recorder.Status()
+	assert.Equal(status, handle.SpanStatusError)
+	assert.Equal(msg, "read: EOF")
+}
+
+func TestSpan_panic(t *testing.T) {
+	var recorder handle.SpanRecorder
+	func() {
+		defer func() {
+			panicked, ok := recover().(handle.PanicAnnotated)
+			assert.That(ok, "the panic is rethrown")
+			assert.That(panicked.Panic == "boom", "the panic is kept")
+		}()
+		_ = func() (err error) {
+			defer handle.Span(&err, &recorder)
+			defer handle.Wrap(&err, "annotated")
+			panic("boom")
+		}()
+	}()
+	recorded := recorder.Errors()
+	assert.SLen(recorded, 1)
+	assert.Equal(recorded[0].Err.Error(), "annotated: boom")
+	assert.Equal(recorded[0].Attr("panic.value"), "boom")
+	assert.Equal(recorded[0].Attr("panic.type"), "string")
+	assert.That(strings.HasPrefix(recorded[0].Attr("exception.stacktrace"), "panic: boom\ngithub.com/gregwebs/try/handle_test.TestSpan_panic.func"),
+		"the stack starts at the panic: "+recorded[0].Attr("exception.stacktrace"))
+
+	recorder = handle.SpanRecorder{}
+	func() {
+		defer func() { _ = recover() }()
+		_ = func() (err error) {
+			defer handle.Span(&err, &recorder)
+			panicIndex(1)
+			return nil
+		}()
+	}()
+	recorded = recorder.Errors()
+	assert.SLen(recorded, 1)
+	stacktrace := recorded[0].Attr("exception.stacktrace")
+	assert.That(strings.HasPrefix(stacktrace, "panic: runtime error: index out of range [1] with length 0\ngithub.com/gregwebs/try/handle_test.panicIndex\n"),
+		"the stack of a panic that was not annotated starts at the panic: "+stacktrace)
+}
+
+func TestKind(t *testing.T) {
+	f := func(thrown error) (err error) {
+		defer handle.Kind(&err, try.Unavailable)
+		try.CheckKind(thrown, try.InvalidArgument, "parse %s", "x")
+		return nil
+	}
+	err := f(io.EOF)
+	assert.Equal(err.Error(), "parse x: EOF")
+	assert.Equal(try.KindOf(err), try.Unavailable, "the outermost kind")
+	assert.That(errors.Is(err, try.Unavailable) && errors.Is(err, try.InvalidArgument), "kinds are sentinels")
+	assert.That(!errors.Is(err, try.NotFound), "not tagged with NotFound")
+	assert.Equal(try.HTTPStatus(err), http.StatusServiceUnavailable)
+
+	assert.Equal(try.KindOf(io.EOF), try.Unknown)
+	assert.Equal(try.HTTPStatus(io.EOF), http.StatusInternalServerError)
+	assert.Equal(try.KindOf(fmt.Errorf("wrapped: %w", context.DeadlineExceeded)), try.DeadlineExceeded)
+}
+
+func TestKind_sentinel(t *testing.T) {
+	f := func() (err error) {
+		defer handle.Do(&err, try.Replace(io.EOF, errUserNotFound))
+		try.Check(io.EOF)
+		return nil
+	}
+	err := f()
+	assert.That(errors.Is(err, errUserNotFound), "replaced with the sentinel")
+	assert.Equal(try.KindOf(err), try.NotFound)
+	assert.That(errors.Is(err, try.NotFound), "the sentinel is its Kind")
+
+	err = try.Mark(errUserNotFound)(io.EOF)
+	assert.That(errors.Is(err, errUserNotFound) && errors.Is(err, io.EOF), "marked with the sentinel")
+	assert.Equal(try.KindOf(err), try.NotFound)
+	assert.That(errors.Is(err, try.NotFound), "errors.Is agrees with KindOf")
+	assert.That(!errors.Is(err, try.Conflict), "only the Kind of the sentinel")
+	assert.Equal(try.HTTPStatus(err), http.StatusNotFound)
+}
+
+// fieldErrors is not comparable
+type fieldErrors []string
+
+func (f fieldErrors) Error() string { return strings.Join(f, ", ") }
+
+func TestMark_notComparable(t *testing.T) {
+	err := try.Mark(fieldErrors{"name"})(io.EOF)
+	assert.That(!errors.Is(err, fieldErrors{"name"}), "a sentinel that is not comparable does not panic")
+	assert.That(errors.Is(err, io.EOF), "the marked error is kept")
+}
+
 func TestDefault_Error(t *testing.T) {
 	var err error
 	defer handle.Do(&err, nil)
@@ -317,7 +1090,7 @@ func TestPanicking_Handlef(t *testing.T) {
 						}
 					}()
 					defer handle.Format(&err, "handlef")
-					handle.AnnotatePanics = true
+					handle.Config.SetAnnotatePanics(true)
 					panic("general panic")
 				},
 			},
@@ -459,6 +1232,88 @@ func TestCatch_All(t *testing.T) {
 	t.Fail() // If everything works we are never here
 }
 
+type crashes []*handle.Crash
+
+func (c *crashes) Report(crash *handle.Crash) error {
+	*c = append(*c, crash)
+	return nil
+}
+
+func panicIndex(i int) {
+	_ = []int{}[i]
+}
+
+func TestReport(t *testing.T) {
+	var reported crashes
+	func() {
+		defer handle.CatchReport(&reported)
+		func() (err error) {
+			defer handle.Wrap(&err, "annotated")
+			panicIndex(1)
+			return nil
+		}()
+	}()
+	func() {
+		defer handle.CatchReport(&reported)
+		try.Checkw(io.EOF, "read")
+	}()
+	func() {
+		defer handle.CatchReport(&reported)
+	}()
+
+	assert.SLen(reported, 2)
+	c := reported[0]
+	assert.That(strings.Contains(c.Panic, "index out of range"), c.Panic)
+	assert.Equal(c.PanicType, "runtime.boundsError")
+	assert.That(strings.HasPrefix(c.Error, "annotated: "), c.Error)
+	assert.SLen(c.ErrorChain, 3)
+	assert.That(strings.HasPrefix(c.PanicStack, "github.com/gregwebs/try/handle_test.panicIndex\n"), "the stack starts at the panic: "+c.PanicStack)
+	assert.That(strings.Contains(c.Goroutines, "handle_test.TestReport"), c.Goroutines)
+	assert.That(!strings.Contains(c.Goroutines, "stackprint.FprintGoroutines("), c.Goroutines)
+	assert.That(c.Build != nil && c.Build.GoVersion != "", "build information is recorded")
+	assert.That(!c.Time.IsZero(), "the time is recorded")
+
+	c = reported[1]
+	assert.Equal(c.Error, "read: EOF")
+	assert.Equal(c.Panic, "")
+	assert.That(errors.Is(c.Err, io.EOF), "the error is recorded")
+	assert.That(strings.HasPrefix(c.PanicStack, "github.com/gregwebs/try/handle_test.TestReport.func"), c.PanicStack)
+}
+
+func TestReport_repanic(t *testing.T) {
+	var reported crashes
+	defer func() {
+		assert.Equal(recover(), any("crash"))
+		assert.SLen(reported, 1)
+		assert.Equal(reported[0].Panic, "crash")
+	}()
+	defer handle.Report(&reported)
+	panic("crash")
+}
+
+func TestFileReporter(t *testing.T) {
+	reporter := handle.NewFileReporter(t.TempDir(), 2)
+	for i := 0; i < 3; i++ {
+		func() {
+			defer handle.CatchReport(reporter)
+			panic(fmt.Sprintf("crash %d", i))
+		}()
+	}
+	paths, err := reporter.Reports()
+	assert.NoError(err)
+	assert.SLen(paths, 2)
+
+	var record map[string]any
+	assert.NoError(json.Unmarshal(try.Check1(os.ReadFile(paths[1])), &record))
+	assert.That(record["panic"] == "crash 2", fmt.Sprint(record["panic"]))
+	assert.That(record["panic_type"] == "string", fmt.Sprint(record["panic_type"]))
+	for _, key := range []string{"time", "error", "error_chain", "panic_stack", "goroutines", "build"} {
+		assert.That(record[key] != nil, "missing "+key)
+	}
+	assert.NoError(json.Unmarshal(try.Check1(os.ReadFile(paths[0])), &record))
+	assert.That(record["panic"] == "crash 1", "the oldest report is removed")
+}
+
 func Example_copyFile() {
 	copyFile := func(src, dst string) (err error) {
 		defer handle.Format(&err, "copy %s %s", src, dst)
@@ -473,7 +1328,7 @@ func Example_copyFile() {
 		defer handle.Cleanup(&err, func() {
 			os.Remove(dst)
 		})
-		defer w.Close()
+		defer handle.Close(&err, w)
 		_, err = io.Copy(w, r)
 		try.Checkw(err, "copy failure")
 		return nil
@@ -571,6 +1426,38 @@ func ExampleDo_with_handler() {
 	// Output: error with (1, 2): this is an ERROR
 }
 
+func benchmarkStack(b *testing.B, apply func(c *try.Settings)) {
+	defer try.Config.Override(apply)()
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		_ = wrapDepth(10)
+	}
+}
+
+func Benchmark_Err_Stack_Eager(b *testing.B) {
+	benchmarkStack(b, func(c *try.Settings) {})
+}
+
+func Benchmark_Err_Stack_Lazy(b *testing.B) {
+	benchmarkStack(b, func(c *try.Settings) { c.SetLazyStack(true) })
+}
+
+func Benchmark_Err_Stack_Depth8(b *testing.B) {
+	benchmarkStack(b, func(c *try.Settings) { c.SetStackDepth(8) })
+}
+
+func Benchmark_Err_Stack_Off(b *testing.B) {
+	benchmarkStack(b, func(c *try.Settings) { c.SetAddStackTrace(false) })
+}
+
+func Benchmark_Err_Stack_Lazy_Print(b *testing.B) {
+	defer try.Config.Override(func(c *try.Settings) { c.SetLazyStack(true) })()
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		_ = fmt.Sprintf("%+v", wrapDepth(10))
+	}
+}
+
 func BenchmarkOldErrorCheckingWithIfClause(b *testing.B) {
 	for n := 0; n < b.N; n++ {
 		_, err := noThrow()
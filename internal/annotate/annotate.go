@@ -0,0 +1,23 @@
+// Package annotate implements the error created by annotating an error with a format string and "%v".
+// It is shared by try.Fmtf and handle.Format.
+package annotate
+
+import "fmt"
+
+// Formatted is an error annotated with a format string.
+// The message includes the message of the annotated error, but the error is not wrapped.
+// The format string is kept so that try.Fingerprint does not depend on the formatted values.
+type Formatted struct {
+	Format string
+	Msg    string
+	// Cause is the annotated error. It is not returned by Unwrap.
+	Cause error
+}
+
+// Errorf annotates the error in the same way as fmt.Errorf(format+": %v", append(args, err)...)
+func Errorf(err error, format string, args ...any) *Formatted {
+	msg := fmt.Sprintf(format+": %v", append(append([]any(nil), args...), err)...)
+	return &Formatted{Format: format, Msg: msg, Cause: err}
+}
+
+func (e *Formatted) Error() string { return e.Msg }
@@ -0,0 +1,109 @@
+// Package config holds the settings that are shared by the try and handle packages.
+package config
+
+import (
+	"os"
+	"strconv"
+	"sync/atomic"
+)
+
+// Config holds settings that are safe to read and change concurrently.
+type Config struct {
+	addStackTrace  atomic.Bool
+	lazyStack      atomic.Bool
+	stackDepth     atomic.Int32
+	annotatePanics atomic.Bool
+}
+
+// DefaultStackDepth is the default maximum number of frames in a stack trace
+const DefaultStackDepth = 32
+
+// Global is the configuration used by the try and handle packages.
+// It is initialized from the environment variables:
+//
+//	TRY_STACK=0            disable adding stack traces
+//	TRY_STACK=lazy         record stack traces without symbolizing them
+//	TRY_STACK_DEPTH=16     set the maximum number of frames in a stack trace
+//	TRY_ANNOTATE_PANICS=0  disable annotating panics
+var Global = FromEnv(os.Getenv)
+
+// New creates a Config with the default settings.
+func New() *Config {
+	c := &Config{}
+	c.addStackTrace.Store(true)
+	c.stackDepth.Store(DefaultStackDepth)
+	c.annotatePanics.Store(true)
+	return c
+}
+
+// FromEnv creates a Config with the default settings overridden by environment variables.
+// Values are parsed with strconv.ParseBool and invalid values are ignored.
+func FromEnv(getenv func(string) string) *Config {
+	c := New()
+	if stack := getenv("TRY_STACK"); stack == "lazy" {
+		c.SetLazyStack(true)
+	} else if b, err := strconv.ParseBool(stack); err == nil {
+		c.SetAddStackTrace(b)
+	}
+	if depth, err := strconv.Atoi(getenv("TRY_STACK_DEPTH")); err == nil {
+		c.SetStackDepth(depth)
+	}
+	if b, err := strconv.ParseBool(getenv("TRY_ANNOTATE_PANICS")); err == nil {
+		c.SetAnnotatePanics(b)
+	}
+	return c
+}
+
+// AddStackTrace tells whether Check* and Handle* wrap errors so that they have a stack trace
+func (c *Config) AddStackTrace() bool { return c.addStackTrace.Load() }
+
+// SetAddStackTrace sets AddStackTrace and returns the previous value
+func (c *Config) SetAddStackTrace(b bool) bool { return c.addStackTrace.Swap(b) }
+
+// LazyStack tells whether stack traces are recorded as program counters only.
+// Symbolizing the stack trace into function names, files, and lines is deferred until it is printed with %+v.
+// This makes the error path cheaper at the cost of doing the work for every print.
+func (c *Config) LazyStack() bool { return c.lazyStack.Load() }
+
+// SetLazyStack sets LazyStack and returns the previous value
+func (c *Config) SetLazyStack(b bool) bool { return c.lazyStack.Swap(b) }
+
+// StackDepth is the maximum number of frames in a stack trace
+func (c *Config) StackDepth() int { return int(c.stackDepth.Load()) }
+
+// SetStackDepth sets StackDepth and returns the previous value.
+// A depth less than 1 is treated as 1.
+func (c *Config) SetStackDepth(depth int) int {
+	if depth < 1 {
+		depth = 1
+	}
+	return int(c.stackDepth.Swap(int32(depth)))
+}
+
+// AnnotatePanics tells whether Handle* functions annotate panics and rethrow them
+func (c *Config) AnnotatePanics() bool { return c.annotatePanics.Load() }
+
+// SetAnnotatePanics sets AnnotatePanics and returns the previous value
+func (c *Config) SetAnnotatePanics(b bool) bool { return c.annotatePanics.Swap(b) }
+
+// Override applies changes to the settings and returns a function that restores the previous settings.
+// It is intended for tests:
+//
+//	defer try.Config.Override(func(c *try.Settings) {
+//		c.SetAddStackTrace(false)
+//	})()
+//
+// The settings are global, so tests using Override should not run in parallel with tests that depend on the settings.
+func (c *Config) Override(apply func(*Config)) (restore func()) {
+	addStackTrace := c.AddStackTrace()
+	lazyStack := c.LazyStack()
+	stackDepth := c.StackDepth()
+	annotatePanics := c.AnnotatePanics()
+	apply(c)
+	return func() {
+		c.SetAddStackTrace(addStackTrace)
+		c.SetLazyStack(lazyStack)
+		c.SetStackDepth(stackDepth)
+		c.SetAnnotatePanics(annotatePanics)
+	}
+}
@@ -0,0 +1,39 @@
+package config
+
+import "testing"
+
+func TestFromEnv(t *testing.T) {
+	env := map[string]string{"TRY_STACK": "0", "TRY_ANNOTATE_PANICS": "not a bool"}
+	c := FromEnv(func(key string) string { return env[key] })
+	if c.AddStackTrace() {
+		t.Error("TRY_STACK=0 should disable AddStackTrace")
+	}
+	if !c.AnnotatePanics() {
+		t.Error("an invalid value should be ignored")
+	}
+
+	env = map[string]string{"TRY_STACK": "lazy", "TRY_STACK_DEPTH": "8"}
+	c = FromEnv(func(key string) string { return env[key] })
+	if !c.AddStackTrace() || !c.LazyStack() {
+		t.Error("TRY_STACK=lazy should enable AddStackTrace and LazyStack")
+	}
+	if c.StackDepth() != 8 {
+		t.Errorf("TRY_STACK_DEPTH=8 should set StackDepth, got %d", c.StackDepth())
+	}
+}
+
+func TestOverride(t *testing.T) {
+	c := New()
+	restore := c.Override(func(c *Config) {
+		c.SetAddStackTrace(false)
+		c.SetStackDepth(4)
+		c.SetAnnotatePanics(false)
+	})
+	if c.AddStackTrace() || c.AnnotatePanics() || c.StackDepth() != 4 {
+		t.Error("Override should apply the settings")
+	}
+	restore()
+	if !c.AddStackTrace() || !c.AnnotatePanics() || c.StackDepth() != DefaultStackDepth {
+		t.Error("restore should restore the previous settings")
+	}
+}
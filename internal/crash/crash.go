@@ -0,0 +1,259 @@
+// Package crash implements the crash reports of handle.Report.
+package crash
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gregwebs/try/internal/panics"
+	"github.com/gregwebs/try/internal/stack"
+	"github.com/gregwebs/try/stackprint"
+)
+
+// Reporter reports a crash
+type Reporter interface {
+	Report(c *Crash) error
+}
+
+// Crash is a report of an error or a panic that was recovered by handle.Report
+type Crash struct {
+	Time     time.Time `json:"time"`
+	Hostname string    `json:"hostname,omitempty"`
+	// Error is the message of the error
+	Error string `json:"error"`
+	// ErrorChain has the type and message of each error in the chain, starting with the outermost error
+	ErrorChain []ChainError `json:"error_chain"`
+	// Panic is the panic value formatted with %v. It is empty if an error was thrown.
+	Panic     string `json:"panic,omitempty"`
+	PanicType string `json:"panic_type,omitempty"`
+	// PanicStack is the stack trace of the place where the panic happened,
+	// or the stack trace of the error if an error was thrown
+	PanicStack string `json:"panic_stack,omitempty"`
+	// Goroutines is the stack traces of all goroutines, formatted by stackprint
+	Goroutines string     `json:"goroutines"`
+	Build      *BuildInfo `json:"build,omitempty"`
+
+	// Err is the error that was recovered
+	Err error `json:"-"`
+	// PanicValue is the panic value or nil if an error was thrown
+	PanicValue any `json:"-"`
+}
+
+// ChainError is an error in the error chain of a Crash
+type ChainError struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
+// BuildInfo is the information from debug.ReadBuildInfo
+type BuildInfo struct {
+	GoVersion string `json:"go_version"`
+	Path      string `json:"path"`
+	Version   string `json:"version,omitempty"`
+	// Settings are the build settings such as vcs.revision
+	Settings map[string]string `json:"settings,omitempty"`
+}
+
+// New creates a Crash from a recovered value.
+// It returns nil if the value is nil.
+func New(r any) *Crash {
+	if r == nil {
+		return nil
+	}
+	c := &Crash{Time: time.Now().UTC()}
+	c.Hostname, _ = os.Hostname()
+	var panicFrames []runtime.Frame
+	if err := panics.Thrown(r); err != nil {
+		c.Err = err
+	} else if annotated, ok := r.(panics.Annotated); ok {
+		c.PanicValue = annotated.Panic
+		c.Err = annotated.Err
+		panicFrames = annotated.PanicStack
+	} else {
+		c.PanicValue = r
+		panicFrames = panics.Stack(1)
+	}
+	if c.PanicValue != nil {
+		c.Panic = fmt.Sprintf("%v", c.PanicValue)
+		c.PanicType = fmt.Sprintf("%T", c.PanicValue)
+		if c.Err == nil {
+			c.Err = stack.New(fmt.Errorf("%+v", c.PanicValue), 1)
+		}
+	}
+	c.Error = c.Err.Error()
+	c.ErrorChain = chain(c.Err)
+	if len(panicFrames) > 0 {
+		c.PanicStack = formatFrames(panicFrames)
+	} else {
+		c.PanicStack = errorStack(c.Err)
+	}
+
+	var goroutines bytes.Buffer
+	stackprint.FprintGoroutines(&goroutines)
+	c.Goroutines = goroutines.String()
+
+	if info, ok := debug.ReadBuildInfo(); ok {
+		c.Build = &BuildInfo{GoVersion: info.GoVersion, Path: info.Path, Version: info.Main.Version}
+		if len(info.Settings) > 0 {
+			c.Build.Settings = make(map[string]string, len(info.Settings))
+			for _, setting := range info.Settings {
+				c.Build.Settings[setting.Key] = setting.Value
+			}
+		}
+	}
+	return c
+}
+
+func chain(err error) []ChainError {
+	var errs []ChainError
+	var walk func(err error)
+	walk = func(err error) {
+		if !stack.IsLoggable(err) {
+			errs = append(errs, ChainError{Type: fmt.Sprintf("%T", err), Message: err.Error()})
+		}
+		switch u := err.(type) {
+		case interface{ Unwrap() error }:
+			if inner := u.Unwrap(); inner != nil {
+				walk(inner)
+			}
+		case interface{ Unwrap() []error }:
+			for _, inner := range u.Unwrap() {
+				if inner != nil {
+					walk(inner)
+				}
+			}
+		}
+	}
+	walk(err)
+	return errs
+}
+
+// errorStack gives the stack trace of the error without the frames of the runtime and this library at the top
+func errorStack(err error) string {
+	var stackErr *stack.Error
+	if !errors.As(err, &stackErr) {
+		return ""
+	}
+	frames := stackErr.Frames()
+	for len(frames) > 1 && stack.IsLibrary(frames[0].Function) {
+		frames = frames[1:]
+	}
+	return formatFrames(frames)
+}
+
+func formatFrames(frames []runtime.Frame) string {
+	var b strings.Builder
+	for _, frame := range frames {
+		fmt.Fprintf(&b, "%s\n\t%s:%d\n", frame.Function, frame.File, frame.Line)
+	}
+	return b.String()
+}
+
+// FileReporter writes each crash as a JSON file in a directory
+type FileReporter struct {
+	Dir string
+	// MaxReports is the number of reports that are kept: the oldest reports are removed.
+	// 0 means no limit.
+	MaxReports int
+	// Prefix is the start of the file names. The default is "crash-".
+	Prefix string
+
+	mu  sync.Mutex
+	seq int
+}
+
+// NewFileReporter creates a FileReporter that keeps at most maxReports reports in dir
+func NewFileReporter(dir string, maxReports int) *FileReporter {
+	return &FileReporter{Dir: dir, MaxReports: maxReports}
+}
+
+func (f *FileReporter) prefix() string {
+	if f.Prefix == "" {
+		return "crash-"
+	}
+	return f.Prefix
+}
+
+// Report writes the crash to a new file named with the prefix and the time, then removes the oldest reports.
+// The file is written to a temporary file first so that a partial report is never seen.
+func (f *FileReporter) Report(c *Crash) error {
+	b, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := os.MkdirAll(f.Dir, 0o755); err != nil {
+		return err
+	}
+	f.seq++
+	name := fmt.Sprintf("%s%s-%d-%04d.json", f.prefix(), c.Time.Format("20060102T150405.000000000Z"), os.Getpid(), f.seq%10000)
+	tmp, err := os.CreateTemp(f.Dir, ".tmp-"+f.prefix())
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(append(b, '\n')); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(f.Dir, name)); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return f.rotate()
+}
+
+// Reports gives the paths of the reports in the directory, oldest first
+func (f *FileReporter) Reports() ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(f.Dir, f.prefix()+"*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+func (f *FileReporter) rotate() error {
+	if f.MaxReports <= 0 {
+		return nil
+	}
+	paths, err := f.Reports()
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for len(paths) > f.MaxReports {
+		if err := os.Remove(paths[0]); err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, err)
+		}
+		paths = paths[1:]
+	}
+	return errors.Join(errs...)
+}
+
+// Report reports a recovered value.
+// If the reporter fails, the crash is printed to standard error instead so that it is not lost.
+func Report(reporter Reporter, r any) {
+	c := New(r)
+	if c == nil {
+		return
+	}
+	if err := reporter.Report(c); err != nil {
+		fmt.Fprintf(os.Stderr, "try: failed to report crash: %v\n%+v\n%s", err, c.Err, c.Goroutines)
+	}
+}
@@ -0,0 +1,159 @@
+// Package kind implements the error kinds that are shared by the try and handle packages.
+package kind
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Kind classifies an error
+type Kind uint8
+
+const (
+	// Unknown is the Kind of an error that has not been given a Kind
+	Unknown Kind = iota
+	InvalidArgument
+	NotFound
+	AlreadyExists
+	Conflict
+	PermissionDenied
+	Unauthenticated
+	FailedPrecondition
+	ResourceExhausted
+	Canceled
+	DeadlineExceeded
+	Unimplemented
+	Unavailable
+	Internal
+)
+
+var names = [...]string{
+	Unknown:            "unknown",
+	InvalidArgument:    "invalid argument",
+	NotFound:           "not found",
+	AlreadyExists:      "already exists",
+	Conflict:           "conflict",
+	PermissionDenied:   "permission denied",
+	Unauthenticated:    "unauthenticated",
+	FailedPrecondition: "failed precondition",
+	ResourceExhausted:  "resource exhausted",
+	Canceled:           "canceled",
+	DeadlineExceeded:   "deadline exceeded",
+	Unimplemented:      "unimplemented",
+	Unavailable:        "unavailable",
+	Internal:           "internal",
+}
+
+// statuses are HTTP status codes. They are literals so that net/http is not linked into programs that do not use it.
+var statuses = [...]int{
+	Unknown:            500,
+	InvalidArgument:    400,
+	NotFound:           404,
+	AlreadyExists:      409,
+	Conflict:           409,
+	PermissionDenied:   403,
+	Unauthenticated:    401,
+	FailedPrecondition: 412,
+	ResourceExhausted:  429,
+	Canceled:           499, // Client Closed Request
+	DeadlineExceeded:   504,
+	Unimplemented:      501,
+	Unavailable:        503,
+	Internal:           500,
+}
+
+func (k Kind) String() string {
+	if int(k) < len(names) {
+		return names[k]
+	}
+	return fmt.Sprintf("kind(%d)", uint8(k))
+}
+
+// Error makes a Kind a sentinel error: errors.Is(err, kind) holds for an error tagged with the kind.
+func (k Kind) Error() string { return k.String() }
+
+// HTTPStatus gives the HTTP status code for the Kind
+func (k Kind) HTTPStatus() int {
+	if int(k) < len(statuses) {
+		return statuses[k]
+	}
+	return 500
+}
+
+// Kinded is implemented by errors that have a Kind.
+// ErrorKind returns Unknown when the error does not have a Kind after all.
+type Kinded interface {
+	ErrorKind() Kind
+}
+
+// Error is an error tagged with a Kind
+type Error struct {
+	Kind Kind
+	Err  error
+}
+
+// Wrap tags an error with a Kind
+func Wrap(err error, k Kind) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Kind: k, Err: err}
+}
+
+func (e *Error) Error() string   { return e.Err.Error() }
+func (e *Error) Unwrap() error   { return e.Err }
+func (e *Error) ErrorKind() Kind { return e.Kind }
+func (e *Error) Is(target error) bool {
+	k, ok := target.(Kind)
+	return ok && k == e.Kind
+}
+
+func (e *Error) Format(s fmt.State, verb rune) {
+	switch verb {
+	case 'v':
+		if s.Flag('+') {
+			fmt.Fprintf(s, "%+v\nkind: %s", e.Err, e.Kind)
+			return
+		}
+		fallthrough
+	case 's':
+		fmt.Fprint(s, e.Error())
+	case 'q':
+		fmt.Fprintf(s, "%q", e.Error())
+	}
+}
+
+// Sentinel is a sentinel error that has a Kind
+type Sentinel struct {
+	Kind Kind
+	Msg  string
+}
+
+func (s *Sentinel) Error() string   { return s.Msg }
+func (s *Sentinel) ErrorKind() Kind { return s.Kind }
+func (s *Sentinel) Is(target error) bool {
+	k, ok := target.(Kind)
+	return ok && k == s.Kind
+}
+
+// Of gives the Kind of the outermost error in the chain that has one.
+// It returns Unknown if no error in the chain has a Kind.
+func Of(err error) Kind {
+	for err != nil {
+		if kinded, ok := err.(Kinded); ok {
+			if k := kinded.ErrorKind(); k != Unknown {
+				return k
+			}
+		}
+		if multi, ok := err.(interface{ Unwrap() []error }); ok {
+			for _, e := range multi.Unwrap() {
+				if k := Of(e); k != Unknown {
+					return k
+				}
+			}
+			return Unknown
+		}
+		err = errors.Unwrap(err)
+	}
+	return Unknown
+}
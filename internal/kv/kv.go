@@ -0,0 +1,108 @@
+// Package kv implements errors annotated with structured key/value fields.
+// It is shared by the try and handle packages.
+package kv
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// badKey is used for a value that is missing a key, the same as log/slog
+const badKey = "!BADKEY"
+
+// Field is a key/value annotation
+type Field struct {
+	Key   string
+	Value any
+}
+
+// Error is an error annotated with a message and key/value fields
+type Error struct {
+	Msg    string
+	Fields []Field
+	Err    error
+}
+
+// Wrap annotates an error with a message and alternating keys and values.
+// A non-string key or a key without a value are recorded under the key "!BADKEY".
+func Wrap(err error, msg string, kvs ...any) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Msg: msg, Fields: Pairs(kvs), Err: err}
+}
+
+// Pairs converts alternating keys and values to fields.
+func Pairs(kvs []any) []Field {
+	fields := make([]Field, 0, (len(kvs)+1)/2)
+	for len(kvs) > 0 {
+		key, ok := kvs[0].(string)
+		if !ok || len(kvs) == 1 {
+			fields = append(fields, Field{Key: badKey, Value: kvs[0]})
+			kvs = kvs[1:]
+			continue
+		}
+		fields = append(fields, Field{Key: key, Value: kvs[1]})
+		kvs = kvs[2:]
+	}
+	return fields
+}
+
+func (e *Error) Error() string {
+	if e.Msg == "" {
+		return e.Err.Error()
+	}
+	return e.Msg + ": " + e.Err.Error()
+}
+
+func (e *Error) Unwrap() error { return e.Err }
+
+func (e *Error) Format(s fmt.State, verb rune) {
+	switch verb {
+	case 'v':
+		if s.Flag('+') {
+			fmt.Fprintf(s, "%+v\n", e.Err)
+			io.WriteString(s, e.Msg)
+			for _, f := range e.Fields {
+				fmt.Fprintf(s, " %s=%v", f.Key, f.Value)
+			}
+			return
+		}
+		fallthrough
+	case 's':
+		io.WriteString(s, e.Error())
+	case 'q':
+		fmt.Fprintf(s, "%q", e.Error())
+	}
+}
+
+// Fields collects the fields from every error in the chain.
+// When a key is repeated, the outermost value is used.
+func Fields(err error) map[string]any {
+	fields := map[string]any{}
+	walk(err, func(err error) {
+		if kvErr, ok := err.(*Error); ok {
+			for _, f := range kvErr.Fields {
+				if _, exists := fields[f.Key]; !exists {
+					fields[f.Key] = f.Value
+				}
+			}
+		}
+	})
+	return fields
+}
+
+// walk visits every error in the chain, including every branch of a multi-error, outermost first.
+func walk(err error, visit func(error)) {
+	for err != nil {
+		visit(err)
+		if multi, ok := err.(interface{ Unwrap() []error }); ok {
+			for _, e := range multi.Unwrap() {
+				walk(e, visit)
+			}
+			return
+		}
+		err = errors.Unwrap(err)
+	}
+}
@@ -0,0 +1,119 @@
+// Package observe implements the hooks that are called when an error is thrown or recovered.
+// It is shared by the try and handle packages.
+package observe
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// ThrowEvent is given to the OnThrow hooks when Check throws an error
+type ThrowEvent struct {
+	// Err is the error that is thrown, after the handlers were applied
+	Err error
+	// Site is the call site of Check
+	Site runtime.Frame
+}
+
+// RecoverKind is the kind of value that a Handle* function recovered
+type RecoverKind uint8
+
+const (
+	// RecoverError is an error thrown by Check
+	RecoverError RecoverKind = iota
+	// RecoverPanic is a panic that was not recovered before
+	RecoverPanic
+	// RecoverAnnotated is a PanicAnnotated that was rethrown by another Handle* function
+	RecoverAnnotated
+)
+
+func (k RecoverKind) String() string {
+	switch k {
+	case RecoverError:
+		return "error"
+	case RecoverPanic:
+		return "panic"
+	case RecoverAnnotated:
+		return "annotated"
+	default:
+		return "unknown"
+	}
+}
+
+// RecoverEvent is given to the OnRecover hooks when a Handle* function recovers an error or a panic
+type RecoverEvent struct {
+	Kind RecoverKind
+	// Err is the error after the handler was applied
+	Err error
+	// Panic is the panic value for RecoverPanic and RecoverAnnotated
+	Panic any
+	// Site is the first function outside of this library on the stack.
+	// For a panic this is where the panic happened.
+	Site runtime.Frame
+}
+
+// Hooks is a list of functions to call with an event.
+// Functions can be added and removed concurrently.
+type Hooks[E any] struct {
+	mu     sync.Mutex
+	nextID int
+	// entries is replaced rather than modified so that Call does not need the lock
+	entries atomic.Pointer[[]entry[E]]
+}
+
+type entry[E any] struct {
+	id int
+	fn func(E)
+}
+
+// Add adds a hook. The returned function removes it.
+func (h *Hooks[E]) Add(fn func(E)) (remove func()) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	id := h.nextID
+	h.nextID++
+	entries := append(h.load(), entry[E]{id: id, fn: fn})
+	h.entries.Store(&entries)
+	return func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		var entries []entry[E]
+		for _, e := range h.load() {
+			if e.id != id {
+				entries = append(entries, e)
+			}
+		}
+		h.entries.Store(&entries)
+	}
+}
+
+func (h *Hooks[E]) load() []entry[E] {
+	if entries := h.entries.Load(); entries != nil {
+		return append([]entry[E](nil), *entries...)
+	}
+	return nil
+}
+
+// Active tells whether there are any hooks, so that creating an event can be avoided
+func (h *Hooks[E]) Active() bool {
+	entries := h.entries.Load()
+	return entries != nil && len(*entries) > 0
+}
+
+// Call calls the hooks in the order they were added
+func (h *Hooks[E]) Call(event E) {
+	entries := h.entries.Load()
+	if entries == nil {
+		return
+	}
+	for _, e := range *entries {
+		e.fn(event)
+	}
+}
+
+// Throw are the hooks called by Check
+var Throw Hooks[ThrowEvent]
+
+// Recover are the hooks called by Handle* functions
+var Recover Hooks[RecoverEvent]
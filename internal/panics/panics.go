@@ -0,0 +1,175 @@
+// Package panics implements the PanicAnnotated type of the handle package.
+// It is shared by the try and handle packages so that both can recognize a rethrown panic.
+package panics
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"runtime"
+
+	"github.com/gregwebs/try/internal/config"
+	"github.com/gregwebs/try/internal/stack"
+)
+
+// Annotated is handle.PanicAnnotated
+type Annotated struct {
+	Panic any
+	// This error is the same as the panic
+	// It allows functions that expect to annotate an error
+	// to provide their annotation
+	Err error
+	// PanicStack is the stack trace of the place where the panic happened.
+	// It is recorded when the panic is first recovered.
+	PanicStack []runtime.Frame
+}
+
+func (p Annotated) Error() string {
+	// %+v should be available to get a stack,
+	// but we shouldn't need it because this
+	// should get thrown in a stack trace
+	return fmt.Sprintf("%+v, %v", p.Panic, p.Err)
+}
+
+// Unwrap gives the annotations and the panic if it is an error
+// so that errors.Is and errors.As find both.
+func (p Annotated) Unwrap() []error {
+	var errs []error
+	if p.Err != nil {
+		errs = append(errs, p.Err)
+	}
+	if err, ok := p.Panic.(error); ok {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+// Format prints the panic with the stack trace of the place where the panic happened with %+v,
+// followed by the annotations and their stack trace.
+func (p Annotated) Format(s fmt.State, verb rune) {
+	switch verb {
+	case 'v':
+		if s.Flag('+') {
+			fmt.Fprintf(s, "panic: %+v", p.Panic)
+			for _, frame := range p.PanicStack {
+				fmt.Fprintf(s, "\n%s\n\t%s:%d", frame.Function, frame.File, frame.Line)
+			}
+			if p.Err != nil {
+				fmt.Fprintf(s, "\n%+v", p.Err)
+			}
+			return
+		}
+		fallthrough
+	case 's':
+		io.WriteString(s, p.Error())
+	case 'q':
+		fmt.Fprintf(s, "%q", p.Error())
+	}
+}
+
+// LogValue satisfies slog.LogValuer
+func (p Annotated) LogValue() slog.Value {
+	attrs := []slog.Attr{slog.String("panic", fmt.Sprintf("%v", p.Panic))}
+	if p.Err != nil {
+		// The stack summary starts at the place of the panic rather than where it was recovered
+		if len(p.PanicStack) > 0 {
+			attrs = append(attrs, slog.Any("error", stack.LogValueAt(p.Err, p.PanicStack)))
+		} else {
+			attrs = append(attrs, slog.Any("error", stack.LogValue(p.Err)))
+		}
+	}
+	if len(p.PanicStack) > 0 {
+		site := p.PanicStack[0]
+		attrs = append(attrs, slog.String("panic_site", fmt.Sprintf("%s %s:%d", site.Function, filepath.Base(site.File), site.Line)))
+	}
+	return slog.GroupValue(attrs...)
+}
+
+// Stack gives the stack trace of the place where a panic happened.
+// It must be called by the deferred function that recovered the panic.
+// skip is the number of stack frames to skip: 0 starts at the caller of Stack.
+// The frames of the deferred functions and the runtime before the place of the panic are removed.
+func Stack(skip int) []runtime.Frame {
+	pcs := make([]uintptr, config.Global.StackDepth()+maxPanicFrames)
+	n := runtime.Callers(skip+2, pcs)
+	iter := runtime.CallersFrames(pcs[:n])
+	var frames []runtime.Frame
+	for {
+		frame, more := iter.Next()
+		frames = append(frames, frame)
+		if !more {
+			break
+		}
+	}
+	for i, frame := range frames {
+		if frame.Function == "runtime.gopanic" {
+			frames = frames[i+1:]
+			// Remove the runtime functions that called panic, such as runtime.panicmem
+			for len(frames) > 1 && stack.FuncPackage(frames[0].Function) == "runtime" {
+				frames = frames[1:]
+			}
+			break
+		}
+	}
+	if len(frames) > config.Global.StackDepth() {
+		frames = frames[:config.Global.StackDepth()]
+	}
+	return frames
+}
+
+// maxPanicFrames is the number of frames allowed for the deferred functions and the runtime above the place of the panic
+const maxPanicFrames = 16
+
+// passThrough are the values registered with PassThrough
+var passThrough []error
+
+// PassThrough registers a panic value that is always re-panicked as is instead of being converted to an error.
+// It must only be called from an init function.
+// tryhttp registers http.ErrAbortHandler so that net/http aborts the response,
+// without the try and handle packages importing net/http.
+func PassThrough(v error) {
+	passThrough = append(passThrough, v)
+}
+
+// IsPassThrough tells whether the recovered value was registered with PassThrough
+func IsPassThrough(r any) bool {
+	for _, v := range passThrough {
+		if r == v {
+			return true
+		}
+	}
+	return false
+}
+
+// Thrown gives the error from a recovered value if it was thrown by try.Check.
+// Otherwise the recovered value is a panic and Thrown returns nil.
+// A value registered with PassThrough is an error but it is a panic.
+func Thrown(r any) error {
+	if IsPassThrough(r) {
+		return nil
+	}
+	switch r := r.(type) {
+	case Annotated:
+		return nil
+	case runtime.Error:
+		return nil
+	case error:
+		return r
+	default:
+		return nil
+	}
+}
+
+// Catch runs the function and recovers an error thrown by try.Check.
+// Panics are not recovered.
+func Catch(fn func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if err = Thrown(r); err == nil {
+				panic(r)
+			}
+		}
+	}()
+	return fn()
+}
@@ -0,0 +1,122 @@
+// Package span implements recording errors to a tracing span for handle.Span.
+package span
+
+import (
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"github.com/gregwebs/try/internal/panics"
+	"github.com/gregwebs/try/internal/stack"
+)
+
+// Status is the status of a span. The values are the same as OpenTelemetry status codes.
+type Status int
+
+const (
+	StatusUnset Status = iota
+	StatusError
+	StatusOK
+)
+
+func (s Status) String() string {
+	switch s {
+	case StatusUnset:
+		return "Unset"
+	case StatusError:
+		return "Error"
+	case StatusOK:
+		return "Ok"
+	default:
+		return fmt.Sprintf("Status(%d)", int(s))
+	}
+}
+
+// Span is the part of a tracing span that handle.Span uses
+type Span interface {
+	RecordError(err error, attrs ...slog.Attr)
+	SetStatus(code Status, msg string)
+}
+
+// Attribute keys follow the OpenTelemetry semantic conventions for exceptions
+const (
+	AttrMessage    = "exception.message"
+	AttrStacktrace = "exception.stacktrace"
+	AttrPanic      = "panic.value"
+	AttrPanicType  = "panic.type"
+)
+
+// Record records the error to the span and sets the status of the span to StatusError.
+// r is the recovered value, which gives the panic value if it is a panic.
+// The stack trace of a panic starts at the place where the panic happened.
+// It must be called while the panic is being recovered.
+func Record(s Span, err error, r any) {
+	attrs := []slog.Attr{slog.String(AttrMessage, err.Error())}
+	if r != nil && panics.Thrown(r) == nil {
+		annotated, ok := r.(panics.Annotated)
+		if !ok {
+			annotated = panics.Annotated{Panic: r, PanicStack: panics.Stack(0)}
+		}
+		annotated.Err = err
+		attrs = append(attrs,
+			slog.String(AttrStacktrace, fmt.Sprintf("%+v", annotated)),
+			slog.String(AttrPanic, fmt.Sprintf("%v", annotated.Panic)),
+			slog.String(AttrPanicType, fmt.Sprintf("%T", annotated.Panic)),
+		)
+	} else if stack.Has(err) {
+		attrs = append(attrs, slog.String(AttrStacktrace, fmt.Sprintf("%+v", err)))
+	}
+	s.RecordError(err, attrs...)
+	s.SetStatus(StatusError, err.Error())
+}
+
+// RecordedError is an error recorded by a Recorder
+type RecordedError struct {
+	Err   error
+	Attrs []slog.Attr
+}
+
+// Attr gives the value of an attribute or "" if it was not recorded
+func (e RecordedError) Attr(key string) string {
+	for _, attr := range e.Attrs {
+		if attr.Key == key {
+			return attr.Value.String()
+		}
+	}
+	return ""
+}
+
+// Recorder is an in-memory Span for tests
+type Recorder struct {
+	mu        sync.Mutex
+	errors    []RecordedError
+	status    Status
+	statusMsg string
+}
+
+func (r *Recorder) RecordError(err error, attrs ...slog.Attr) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.errors = append(r.errors, RecordedError{Err: err, Attrs: attrs})
+}
+
+func (r *Recorder) SetStatus(code Status, msg string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.status = code
+	r.statusMsg = msg
+}
+
+// Errors gives the recorded errors
+func (r *Recorder) Errors() []RecordedError {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]RecordedError(nil), r.errors...)
+}
+
+// Status gives the status and its message
+func (r *Recorder) Status() (Status, string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.status, r.statusMsg
+}
@@ -0,0 +1,80 @@
+package stack
+
+import (
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// libraryPackages are the packages whose frames are skipped by Caller
+var libraryPackages = map[string]bool{
+	"github.com/gregwebs/try":        true,
+	"github.com/gregwebs/try/try":    true,
+	"github.com/gregwebs/try/handle": true,
+}
+
+// internalPrefix is the start of the internal packages of this library
+const internalPrefix = "github.com/gregwebs/try/internal/"
+
+// Caller gives the first frame that is not in the runtime, in this library, or a helper.
+// skip is the number of stack frames to skip: 0 starts the search at the caller of Caller.
+func Caller(skip int) (runtime.Frame, bool) {
+	var pcs [maxCallerDepth]uintptr
+	n := runtime.Callers(skip+2, pcs[:])
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		if !IsLibrary(frame.Function) && !IsHelper(frame.Function) {
+			return frame, true
+		}
+		if !more {
+			return runtime.Frame{}, false
+		}
+	}
+}
+
+const maxCallerDepth = 32
+
+// IsLibrary tells whether a function belongs to the runtime or to this library
+func IsLibrary(function string) bool {
+	pkg := FuncPackage(function)
+	return pkg == "runtime" || strings.HasPrefix(pkg, "runtime/") || libraryPackages[pkg] || strings.HasPrefix(pkg, internalPrefix)
+}
+
+// FuncPackage gives the package path of a function name given by runtime.Frame.Function
+func FuncPackage(function string) string {
+	slash := strings.LastIndex(function, "/")
+	if dot := strings.Index(function[slash+1:], "."); dot >= 0 {
+		return function[:slash+1+dot]
+	}
+	return function
+}
+
+var (
+	// helperPCs are the call sites of MarkHelper that have been seen
+	helperPCs sync.Map
+	// helpers are the names of the functions marked by MarkHelper
+	helpers sync.Map
+)
+
+// MarkHelper marks a function as a helper, similar to testing.T.Helper.
+// Helper functions are omitted from stack traces.
+// skip is the number of stack frames to skip: 0 marks the caller of MarkHelper.
+func MarkHelper(skip int) {
+	var pcs [1]uintptr
+	if runtime.Callers(skip+2, pcs[:]) == 0 {
+		return
+	}
+	if _, seen := helperPCs.Load(pcs[0]); seen {
+		return
+	}
+	frame, _ := runtime.CallersFrames(pcs[:]).Next()
+	helpers.Store(frame.Function, struct{}{})
+	helperPCs.Store(pcs[0], struct{}{})
+}
+
+// IsHelper tells whether a function was marked by MarkHelper
+func IsHelper(function string) bool {
+	_, ok := helpers.Load(function)
+	return ok
+}
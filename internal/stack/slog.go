@@ -0,0 +1,136 @@
+package stack
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/gregwebs/errors"
+	"github.com/gregwebs/try/internal/kv"
+)
+
+// summaryDepth is the number of frames given in the stack summary of a log value
+const summaryDepth = 5
+
+// LogValue satisfies slog.LogValuer
+func (e *Error) LogValue() slog.Value {
+	return LogValue(e)
+}
+
+// LogValue gives the attributes of an error for log/slog:
+// the message, the annotation chain, the structured fields and a stack summary.
+func LogValue(err error) slog.Value {
+	return logValue(err, Summary(err))
+}
+
+// LogValueAt is LogValue with the stack summary of the given frames,
+// such as the stack trace of the place where a panic happened.
+func LogValueAt(err error, frames []runtime.Frame) slog.Value {
+	return logValue(err, summarize(frames))
+}
+
+func logValue(err error, summary []string) slog.Value {
+	attrs := []slog.Attr{slog.String("msg", err.Error())}
+	if annotations := Annotations(err); len(annotations) > 0 {
+		attrs = append(attrs, slog.Any("annotations", annotations))
+	}
+	if fields := kv.Fields(err); len(fields) > 0 {
+		fieldAttrs := make([]any, 0, len(fields))
+		for k, v := range fields {
+			fieldAttrs = append(fieldAttrs, slog.Any(k, v))
+		}
+		attrs = append(attrs, slog.Group("fields", fieldAttrs...))
+	}
+	if len(summary) > 0 {
+		attrs = append(attrs, slog.Any("stack", summary))
+	}
+	return slog.GroupValue(attrs...)
+}
+
+// Loggable gives an error that satisfies slog.LogValuer
+func Loggable(err error) error {
+	if _, ok := err.(slog.LogValuer); ok {
+		return err
+	}
+	return loggable{err}
+}
+
+// IsLoggable tells whether the error is only the wrapper added by Loggable.
+// It has the same message as the error it wraps, so it can be skipped when the error chain is shown.
+func IsLoggable(err error) bool {
+	_, ok := err.(loggable)
+	return ok
+}
+
+type loggable struct{ error }
+
+func (l loggable) Unwrap() error        { return l.error }
+func (l loggable) LogValue() slog.Value { return LogValue(l.error) }
+
+// Format prints the same as the error it wraps
+func (l loggable) Format(s fmt.State, verb rune) {
+	if formatter, ok := l.error.(fmt.Formatter); ok {
+		formatter.Format(s, verb)
+		return
+	}
+	switch verb {
+	case 'v', 's':
+		io.WriteString(s, l.Error())
+	case 'q':
+		fmt.Fprintf(s, "%q", l.Error())
+	}
+}
+
+// Annotations gives the messages that were added to the error chain, outermost first.
+// The message of an error is the part of Error() that precedes the message of the error it wraps.
+func Annotations(err error) []string {
+	var annotations []string
+	for err != nil {
+		next := errors.Unwrap(err)
+		if next == nil {
+			break
+		}
+		if msg, found := strings.CutSuffix(err.Error(), ": "+next.Error()); found && msg != "" {
+			annotations = append(annotations, msg)
+		}
+		err = next
+	}
+	return annotations
+}
+
+// Summary gives the first few frames of the outermost stack trace in the error chain
+// without the frames of the runtime and this library.
+func Summary(err error) []string {
+	var frames []runtime.Frame
+	switch tracer := errors.GetStackTracer(err).(type) {
+	case nil:
+	case *Error:
+		frames = tracer.Frames()
+	default:
+		trace := tracer.StackTrace()
+		pcs := make([]uintptr, len(trace))
+		for i, f := range trace {
+			pcs[i] = uintptr(f)
+		}
+		frames = symbolize(pcs)
+	}
+	return summarize(frames)
+}
+
+// summarize gives the first few frames that are not in the runtime or this library
+func summarize(frames []runtime.Frame) []string {
+	summary := make([]string, 0, summaryDepth)
+	for _, frame := range frames {
+		if len(summary) == summaryDepth {
+			break
+		}
+		if IsLibrary(frame.Function) {
+			continue
+		}
+		summary = append(summary, fmt.Sprintf("%s %s:%d", frame.Function, filepath.Base(frame.File), frame.Line))
+	}
+	return summary
+}
@@ -0,0 +1,152 @@
+// Package stack implements the error with a stack trace that is created by the try and handle packages.
+package stack
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+
+	"github.com/gregwebs/errors"
+	"github.com/gregwebs/try/internal/config"
+)
+
+// Error is an error with a stack trace
+type Error struct {
+	Err error
+	pcs []uintptr
+	// frames is nil when the stack is lazy: it is symbolized when needed
+	frames []runtime.Frame
+}
+
+// Has tells whether the error chain has a stack trace
+func Has(err error) bool {
+	return errors.HasStack(err)
+}
+
+// Add adds a stack trace to the error if the error chain does not already have one.
+// skip is the number of stack frames to skip: 0 starts the stack trace at the caller of Add.
+func Add(err error, skip int) error {
+	if err == nil || Has(err) {
+		return err
+	}
+	return New(err, skip+1)
+}
+
+// Wrap annotates the error with a message.
+// A stack trace is added if the error chain does not already have one.
+// skip is the number of stack frames to skip: 0 starts the stack trace at the caller of Wrap.
+func Wrap(err error, msg string, skip int) error {
+	if err == nil {
+		return nil
+	}
+	return Annotated(errors.WithMessage(err, msg), skip+1)
+}
+
+// Annotated adds a stack trace to an error that was annotated by this library
+// if the error chain does not already have one.
+// Otherwise the error is made a slog.LogValuer without a stack trace
+// so that the annotation is still logged.
+// skip is the number of stack frames to skip: 0 starts the stack trace at the caller of Annotated.
+func Annotated(err error, skip int) error {
+	if err == nil {
+		return nil
+	}
+	if Has(err) {
+		return Loggable(err)
+	}
+	return New(err, skip+1)
+}
+
+// New adds a stack trace to the error.
+// skip is the number of stack frames to skip: 0 starts the stack trace at the caller of New.
+//
+// The depth of the stack trace and whether it is symbolized now or lazily are given by config.Global.
+func New(err error, skip int) error {
+	if err == nil {
+		return nil
+	}
+	pcs := make([]uintptr, config.Global.StackDepth())
+	n := runtime.Callers(skip+2, pcs)
+	e := &Error{Err: err, pcs: pcs[:n]}
+	if !config.Global.LazyStack() {
+		e.frames = symbolize(e.pcs)
+	}
+	return e
+}
+
+// symbolize gives the frames of the stack trace, omitting helper functions
+func symbolize(pcs []uintptr) []runtime.Frame {
+	frames := make([]runtime.Frame, 0, len(pcs))
+	if len(pcs) == 0 {
+		return frames
+	}
+	iter := runtime.CallersFrames(pcs)
+	for {
+		frame, more := iter.Next()
+		if !IsHelper(frame.Function) {
+			frames = append(frames, frame)
+		}
+		if !more {
+			break
+		}
+	}
+	return frames
+}
+
+// isHelperPC tells whether all of the functions at a program counter are helpers.
+// There is more than one function when functions are inlined.
+func isHelperPC(pc uintptr) bool {
+	iter := runtime.CallersFrames([]uintptr{pc})
+	for {
+		frame, more := iter.Next()
+		if !IsHelper(frame.Function) {
+			return false
+		}
+		if !more {
+			return true
+		}
+	}
+}
+
+func (e *Error) Error() string  { return e.Err.Error() }
+func (e *Error) Unwrap() error  { return e.Err }
+func (e *Error) HasStack() bool { return true }
+
+// Frames gives the symbolized stack trace.
+// Functions marked by MarkHelper are omitted.
+func (e *Error) Frames() []runtime.Frame {
+	if e.frames != nil {
+		return e.frames
+	}
+	return symbolize(e.pcs)
+}
+
+// StackTrace satisfies errors.StackTracer
+func (e *Error) StackTrace() errors.StackTrace {
+	frames := make([]errors.Frame, 0, len(e.pcs))
+	for _, pc := range e.pcs {
+		if !isHelperPC(pc) {
+			frames = append(frames, errors.Frame(pc))
+		}
+	}
+	return frames
+}
+
+// Format prints the stack trace with %+v in the same format as github.com/gregwebs/errors
+func (e *Error) Format(s fmt.State, verb rune) {
+	switch verb {
+	case 'v':
+		if s.Flag('+') {
+			fmt.Fprintf(s, "%+v", e.Err)
+			for _, frame := range e.Frames() {
+				fmt.Fprintf(s, "\n%s\n\t%s:%d", frame.Function, frame.File, frame.Line)
+			}
+			return
+		}
+		fallthrough
+	case 's':
+		io.WriteString(s, e.Error())
+	case 'q':
+		fmt.Fprintf(s, "%q", e.Error())
+	}
+}
@@ -0,0 +1,91 @@
+package try
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+
+	"github.com/gregwebs/try/internal/kind"
+)
+
+// Kind classifies an error so that it can be handled without inspecting the message.
+// A Kind is also a sentinel error: errors.Is(err, try.NotFound) holds for an error tagged with NotFound.
+// HTTPStatus gives the HTTP status code for a Kind.
+type Kind = kind.Kind
+
+const (
+	// Unknown is the Kind of an error that has not been given a Kind
+	Unknown            = kind.Unknown
+	InvalidArgument    = kind.InvalidArgument
+	NotFound           = kind.NotFound
+	AlreadyExists      = kind.AlreadyExists
+	Conflict           = kind.Conflict
+	PermissionDenied   = kind.PermissionDenied
+	Unauthenticated    = kind.Unauthenticated
+	FailedPrecondition = kind.FailedPrecondition
+	ResourceExhausted  = kind.ResourceExhausted
+	Canceled           = kind.Canceled
+	DeadlineExceeded   = kind.DeadlineExceeded
+	Unimplemented      = kind.Unimplemented
+	Unavailable        = kind.Unavailable
+	Internal           = kind.Internal
+)
+
+// KindOf gives the Kind of the outermost error in the chain that has one.
+// Context errors that have not been given a Kind are Canceled or DeadlineExceeded.
+// Otherwise it returns Unknown.
+func KindOf(err error) Kind {
+	if k := kind.Of(err); k != Unknown {
+		return k
+	}
+	switch {
+	case stderrors.Is(err, context.Canceled):
+		return Canceled
+	case stderrors.Is(err, context.DeadlineExceeded):
+		return DeadlineExceeded
+	}
+	return Unknown
+}
+
+// HTTPStatus gives the HTTP status code for the Kind of the error.
+// An error without a Kind is a 500 Internal Server Error.
+func HTTPStatus(err error) int {
+	return KindOf(err).HTTPStatus()
+}
+
+// NewSentinel creates a sentinel error that has a Kind.
+// An error marked with the sentinel has the Kind of the sentinel.
+//
+//	var ErrUserNotFound = try.NewSentinel(try.NotFound, "user not found")
+//
+//	try.Check(err, try.Mark(ErrUserNotFound))
+//	// errors.Is(err, ErrUserNotFound) && try.KindOf(err) == try.NotFound && errors.Is(err, try.NotFound)
+func NewSentinel(k Kind, msg string) error {
+	return &kind.Sentinel{Kind: k, Msg: msg}
+}
+
+// WithKind creates an error handler that tags the error with a Kind
+func WithKind(k Kind) func(error) error {
+	return func(err error) error {
+		return kind.Wrap(err, k)
+	}
+}
+
+// CheckKind is Check that tags the error with a Kind.
+// An optional message is given as a format string followed by its arguments, the same as Checkw.
+//
+//	try.CheckKind(err, try.NotFound, "user %d", id)
+func CheckKind(err error, k Kind, msg ...any) {
+	if err == nil {
+		return
+	}
+	if len(msg) == 0 {
+		throw(err, 1, []func(error) error{WithKind(k)})
+		return
+	}
+	format, ok := msg[0].(string)
+	if !ok {
+		format = fmt.Sprint(msg[0])
+	}
+	throw(err, 1, []func(error) error{Fmtw(format, msg[1:]...), WithKind(k)})
+}
@@ -0,0 +1,156 @@
+package try
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gregwebs/try/internal/observe"
+)
+
+// ThrowEvent is given to the OnThrow hooks
+//
+//	type ThrowEvent struct {
+//		// Err is the error that is thrown, after the handlers were applied
+//		Err error
+//		// Site is the call site of Check
+//		Site runtime.Frame
+//	}
+type ThrowEvent = observe.ThrowEvent
+
+// OnThrow adds a hook that is called when a Check* function throws an error.
+// Hooks are called in the order they were added, before the error is thrown.
+// Hooks must not panic. The returned function removes the hook.
+func OnThrow(fn func(ThrowEvent)) (remove func()) {
+	return observe.Throw.Add(fn)
+}
+
+// SiteCount is the number of events at a call site
+type SiteCount struct {
+	// Event is "throw" for errors thrown by Check*
+	// or "recover" for errors and panics recovered by Handle* functions
+	Event string
+	// Kind is the kind of recovery: "error", "panic", or "annotated". It is empty for a throw.
+	Kind     string
+	Function string
+	// File is the base name of the file
+	File  string
+	Line  int
+	Count uint64
+}
+
+type siteKey struct {
+	event, kind, function, file string
+	line                        int
+}
+
+// SiteCounter counts the errors that are thrown and recovered at each call site.
+// It can be published with expvar:
+//
+//	expvar.Publish("try", try.NewSiteCounter())
+//
+// and exported to Prometheus with WritePrometheus.
+type SiteCounter struct {
+	mu     sync.Mutex
+	counts map[siteKey]uint64
+	stop   []func()
+}
+
+// NewSiteCounter creates a SiteCounter and starts counting with OnThrow and OnRecover hooks.
+func NewSiteCounter() *SiteCounter {
+	c := &SiteCounter{counts: map[siteKey]uint64{}}
+	c.stop = []func(){
+		observe.Throw.Add(func(e observe.ThrowEvent) {
+			c.add(siteKey{event: "throw", function: e.Site.Function, file: filepath.Base(e.Site.File), line: e.Site.Line})
+		}),
+		observe.Recover.Add(func(e observe.RecoverEvent) {
+			c.add(siteKey{event: "recover", kind: e.Kind.String(), function: e.Site.Function, file: filepath.Base(e.Site.File), line: e.Site.Line})
+		}),
+	}
+	return c
+}
+
+func (c *SiteCounter) add(key siteKey) {
+	c.mu.Lock()
+	c.counts[key]++
+	c.mu.Unlock()
+}
+
+// Stop stops counting. The counts are kept.
+func (c *SiteCounter) Stop() {
+	for _, stop := range c.stop {
+		stop()
+	}
+}
+
+// Counts gives the counts sorted by event, kind, function, and line
+func (c *SiteCounter) Counts() []SiteCount {
+	c.mu.Lock()
+	counts := make([]SiteCount, 0, len(c.counts))
+	for key, n := range c.counts {
+		counts = append(counts, SiteCount{
+			Event: key.event, Kind: key.kind, Function: key.function, File: key.file, Line: key.line, Count: n,
+		})
+	}
+	c.mu.Unlock()
+	sort.Slice(counts, func(i, j int) bool {
+		a, b := counts[i], counts[j]
+		if a.Event != b.Event {
+			return a.Event > b.Event // throw before recover
+		}
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		if a.Function != b.Function {
+			return a.Function < b.Function
+		}
+		return a.Line < b.Line
+	})
+	return counts
+}
+
+// String gives the counts as JSON so that the SiteCounter satisfies expvar.Var
+func (c *SiteCounter) String() string {
+	b, err := json.Marshal(c.Counts())
+	if err != nil {
+		return "null"
+	}
+	return string(b)
+}
+
+// WritePrometheus writes the counts in the Prometheus text exposition format
+// as the counters try_throws_total and try_recovers_total.
+func (c *SiteCounter) WritePrometheus(w io.Writer) error {
+	var b strings.Builder
+	counts := c.Counts()
+	metrics := []struct{ event, name, help string }{
+		{"throw", "try_throws_total", "Errors thrown by Check by call site."},
+		{"recover", "try_recovers_total", "Errors and panics recovered by Handle by kind and call site."},
+	}
+	for _, metric := range metrics {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s counter\n", metric.name, metric.help, metric.name)
+		for _, count := range counts {
+			if count.Event != metric.event {
+				continue
+			}
+			b.WriteString(metric.name + "{")
+			if count.Kind != "" {
+				fmt.Fprintf(&b, "kind=\"%s\",", promEscape(count.Kind))
+			}
+			fmt.Fprintf(&b, "function=\"%s\",file=\"%s\",line=\"%d\"} %d\n",
+				promEscape(count.Function), promEscape(count.File), count.Line, count.Count)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+var promEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func promEscape(s string) string {
+	return promEscaper.Replace(s)
+}
@@ -7,6 +7,7 @@ import (
 	"io"
 	"os"
 	"regexp"
+	"runtime"
 	"runtime/debug"
 	"strings"
 )
@@ -63,6 +64,23 @@ var (
 	PackageRegexp = regexp.MustCompile(`(lainio|gregwebs)/(err(2|3)|try)[a-zA-Z0-9_/.\[\]]*\(`)
 )
 
+// AddPackages adds packages to PackageRegexp.
+// Packages with helper functions that wrap this library can be added
+// so that their stack lines are skipped in the same way as the lines of this library.
+//
+//	stackprint.AddPackages("example.com/app/errutil")
+//
+// AddPackages should be called during initialization, before stacks are printed.
+func AddPackages(pkgPaths ...string) {
+	pattern := PackageRegexp.String()
+	for _, pkgPath := range pkgPaths {
+		pattern += `|` + regexp.QuoteMeta(pkgPath) + `\.[a-zA-Z0-9_.\[\]]*\(`
+	}
+	PackageRegexp = regexp.MustCompile(pattern)
+	stackPrologueError = newErrSI()
+	stackProloguePanic = newSI("", "panic(", 1)
+}
+
 func (si stackInfo) fullName() string {
 	dot := ""
 	if si.PackageName != "" && si.FuncName != "" {
@@ -86,6 +104,30 @@ func (si stackInfo) isFuncAnchor(s string) bool {
 	return strings.Contains(s, si.fullName())
 }
 
+// maxGoroutinesDump limits the size of the dump of FprintGoroutines
+const maxGoroutinesDump = 64 << 20
+
+// FprintGoroutines prints the stack traces of all goroutines returned by runtime.Stack to the writer.
+// The stack trace of the current goroutine starts at the caller of FprintGoroutines.
+func FprintGoroutines(w io.Writer) {
+	buf := make([]byte, 64<<10)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) || len(buf) >= maxGoroutinesDump {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+	for i, goroutine := range bytes.Split(buf, []byte("\n\n")) {
+		if i == 0 {
+			stackPrint(bytes.NewReader(goroutine), w, stackInfo{PackageName: "stackprint", FuncName: "FprintGoroutines(", Level: 1})
+			continue
+		}
+		fmt.Fprintf(w, "\n%s\n", bytes.TrimSuffix(goroutine, []byte("\n")))
+	}
+}
+
 // PrintStack prints to standard error the stack trace returned by runtime.Stack
 // by starting from stackLevel.
 func PrintStack(stackLevel int) {
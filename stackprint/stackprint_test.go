@@ -159,6 +159,37 @@ func TestStackPrint_noLimits(t *testing.T) {
 	}
 }
 
+func TestAddPackages(t *testing.T) {
+	original := PackageRegexp
+	defer func() {
+		PackageRegexp = original
+		stackPrologueError = newErrSI()
+		stackProloguePanic = newSI("", "panic(", 1)
+	}()
+
+	helperLine := "example.com/app/errutil.Checkw(...)"
+	require(t, !PackageRegexp.MatchString(helperLine), "helper packages are not matched by default")
+	AddPackages("example.com/app/errutil")
+	require(t, PackageRegexp.MatchString(helperLine), "added packages are matched")
+	require(t, PackageRegexp.MatchString("example.com/app/errutil.(*Checker).Check(...)"), "methods are matched")
+	require(t, !PackageRegexp.MatchString("example.com/app/errutilx.Checkw(...)"), "only the package is matched")
+	require(t, PackageRegexp.MatchString("github.com/gregwebs/try/try.Check(...)"), "this library is still matched")
+	require(t, stackPrologueError.Regexp == PackageRegexp, "the prologues use the new regexp")
+}
+
+func TestFprintGoroutines(t *testing.T) {
+	done := make(chan struct{})
+	defer close(done)
+	go func() { <-done }()
+	var b bytes.Buffer
+	FprintGoroutines(&b)
+	out := b.String()
+	require(t, strings.HasPrefix(out, "goroutine "), out)
+	require(t, !strings.Contains(out, "stackprint.FprintGoroutines("), "the stack starts at the caller: ", out)
+	require(t, strings.Contains(out, "stackprint.TestFprintGoroutines("), "the current goroutine is printed: ", out)
+	require(t, strings.Contains(out, "stackprint.TestFprintGoroutines.func1("), "other goroutines are printed: ", out)
+}
+
 func TestCalcAnchor(t *testing.T) {
 	type args struct {
 		input string
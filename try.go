@@ -26,27 +26,58 @@ checking.
 package try
 
 import (
+	stderrors "errors"
 	"fmt"
 
-	"github.com/gregwebs/errors"
+	"github.com/gregwebs/try/internal/annotate"
+	"github.com/gregwebs/try/internal/config"
+	"github.com/gregwebs/try/internal/kv"
+	"github.com/gregwebs/try/internal/observe"
+	"github.com/gregwebs/try/internal/stack"
 )
 
-var AddStackTrace bool = true
+// Settings is the type of Config
+type Settings = config.Config
 
-func fmtw(format string, args ...interface{}) func(error) error {
+// Config holds the settings for the try and handle packages.
+// The settings can be read and changed concurrently.
+// They are initialized from environment variables, for example TRY_STACK=0 disables adding stack traces.
+var Config *Settings = config.Global
+
+// Fmtw creates an error handler that wraps the error with a message.
+// This is similar to using "%w" in a format string.
+//
+//	try.Try(err, try.Fmtw("read %s", name))
+func Fmtw(format string, args ...interface{}) func(error) error {
 	return func(err error) error {
-		args = append(args, err)
-		return fmt.Errorf(format+": %w", args...)
+		// The captured args must not be appended to: the handler can be called more than once
+		return fmt.Errorf(format+": %w", append(append([]any(nil), args...), err)...)
 	}
 }
 
-func fmtf(format string, args ...interface{}) func(error) error {
+// Fmtf creates an error handler that annotates the error with a message.
+// This is similar to using "%v" in a format string.
+func Fmtf(format string, args ...interface{}) func(error) error {
 	return func(err error) error {
-		args = append(args, err)
-		return fmt.Errorf(format+": %v", args...)
+		return annotate.Errorf(err, format, args...)
 	}
 }
 
+// Fmtkv creates an error handler that annotates the error with a message and structured fields.
+// The fields are given as alternating keys and values, the same as log/slog.
+// Error() gives just the message: the fields can be retrieved with Fields.
+func Fmtkv(msg string, kvs ...any) func(error) error {
+	return func(err error) error {
+		return kv.Wrap(err, msg, kvs...)
+	}
+}
+
+// Fields collects the structured fields added by Checkkv or Handlekv from every error in the chain.
+// When a key is repeated, the outermost value is used.
+func Fields(err error) map[string]any {
+	return kv.Fields(err)
+}
+
 // A helper function for creating an error handler that performs a cleanup action
 // If you want the action to be run for any error, you can use try.HandleCleanup(&err, cleanup) instead.
 //
@@ -80,8 +111,41 @@ func Cleanup(handler func()) func(error) error {
 // You must use try.Handle at the top of your function to recover the error and return it instead of letting the panic continue to unwind
 //
 // By default, Check will wrap the error so that it has a stack trace
-// This can be disabled by setting the var AddStackTrace = false
+// The error will then satisfy slog.LogValuer.
+// This can be disabled with Config.SetAddStackTrace(false)
 func Check(err error, handlers ...func(error) error) {
+	throw(err, 1, handlers)
+}
+
+// CheckSkip is Check for helper functions that wrap Check.
+// skip is the number of stack frames to skip in the stack trace of the error:
+// 0 starts the stack trace at the caller of CheckSkip, the same as Check,
+// and 1 starts it at the caller of the helper.
+//
+//	func checkQuery(err error, query string) {
+//		try.CheckSkip(err, 1, try.Fmtw("query %s", query))
+//	}
+//
+// Helper marks a function as a helper without needing to count frames.
+func CheckSkip(err error, skip int, handlers ...func(error) error) {
+	throw(err, skip+1, handlers)
+}
+
+// Helper marks the calling function as a helper, similar to testing.T.Helper.
+// Helper functions are omitted from the stack traces recorded by Check* and Handle* functions,
+// so the stack trace starts at the real call site rather than at a helper that wraps Check.
+//
+//	func checkQuery(err error, query string) {
+//		try.Helper()
+//		try.Checkw(err, "query %s", query)
+//	}
+func Helper() {
+	stack.MarkHelper(1)
+}
+
+// throw applies the handlers and panics with the error if it is non-nil.
+// skip is the number of stack frames to skip: 0 starts the stack trace at the caller of throw.
+func throw(err error, skip int, handlers []func(error) error) {
 	if err == nil {
 		return
 	}
@@ -89,7 +153,7 @@ func Check(err error, handlers ...func(error) error) {
 		if handler == nil {
 			continue
 		}
-		
+
 		// This both handles the fact that we allow cleanup functions
 		// that intentionally return nil,
 		// and doesn't allow a handler to accidentally eliminate the error by returning nil
@@ -98,21 +162,230 @@ func Check(err error, handlers ...func(error) error) {
 		}
 	}
 
-	if AddStackTrace {
-		err = errors.AddStack(err)
+	if Config.AddStackTrace() {
+		err = stack.Add(err, skip+1)
+	}
+
+	if observe.Throw.Active() {
+		site, _ := stack.Caller(skip + 1)
+		observe.Throw.Call(observe.ThrowEvent{Err: err, Site: site})
 	}
 
 	panic(err)
 }
 
+// CheckIgnore is Check, except that errors matching one of the targets with errors.Is are not thrown.
+// It returns true if the error was ignored.
+//
+//	if try.CheckIgnore(err, io.EOF) {
+//		break
+//	}
+//
+// Any other non-nil error is thrown in the same way as Check.
+func CheckIgnore(err error, targets ...error) (ignored bool) {
+	if err == nil {
+		return false
+	}
+	if isAny(err, targets) {
+		return true
+	}
+	throw(err, 1, nil)
+	return false
+}
+
+// Or returns the fallback value instead of throwing when the error matches one of the targets with errors.Is.
+// If no targets are given, the fallback is used for any error.
+//
+//	b, err := os.ReadFile(path)
+//	b = try.Or(b, err, nil, fs.ErrNotExist)
+//
+// Any other non-nil error is thrown in the same way as Check.
+func Or[T any](v T, err error, fallback T, targets ...error) T {
+	if err == nil {
+		return v
+	}
+	if len(targets) == 0 || isAny(err, targets) {
+		return fallback
+	}
+	throw(err, 1, nil)
+	return v
+}
+
+// OrElse is Or, except that the fallback value is computed from the error.
+func OrElse[T any](v T, err error, fallback func(error) T, targets ...error) T {
+	if err == nil {
+		return v
+	}
+	if len(targets) == 0 || isAny(err, targets) {
+		return fallback(err)
+	}
+	throw(err, 1, nil)
+	return v
+}
+
+func isAny(err error, targets []error) bool {
+	for _, target := range targets {
+		if stderrors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// Try is the same as Check.
+// It reads better when the error is given handlers.
+//
+//	try.Try(err, try.Cleanup(func() {
+//		os.Remove(dst)
+//	}))
+func Try(err error, handlers ...func(error) error) {
+	throw(err, 1, handlers)
+}
+
+// Try1 is Try for a function that returns a value and an error.
+// Go does not allow additional arguments to be passed along with a multi-value function call,
+// so the handlers are given to the returned function:
+//
+//	x := try.Try1(f())(try.Fmtw("called f"))
+func Try1[T any](v T, err error) func(handlers ...func(error) error) T {
+	return func(handlers ...func(error) error) T {
+		if err != nil {
+			throw(err, 1, handlers)
+		}
+		return v
+	}
+}
+
+// Try2 is Try1 for a function that returns two values and an error.
+func Try2[T any, U any](v1 T, v2 U, err error) func(handlers ...func(error) error) (T, U) {
+	return func(handlers ...func(error) error) (T, U) {
+		if err != nil {
+			throw(err, 1, handlers)
+		}
+		return v1, v2
+	}
+}
+
+// Try3 is Try1 for a function that returns three values and an error.
+func Try3[T any, U any, V any](v1 T, v2 U, v3 V, err error) func(handlers ...func(error) error) (T, U, V) {
+	return func(handlers ...func(error) error) (T, U, V) {
+		if err != nil {
+			throw(err, 1, handlers)
+		}
+		return v1, v2, v3
+	}
+}
+
 func Checkw(err error, format string, args ...interface{}) {
-	Check(err, fmtw(format, args...))
+	throw(err, 1, []func(error) error{Fmtw(format, args...)})
 }
 
 func Checkf(err error, format string, args ...interface{}) {
-	Check(err, fmtf(format, args...))
+	throw(err, 1, []func(error) error{Fmtf(format, args...)})
+}
+
+// Checkkv is Check with an annotation that has structured fields.
+//
+//	try.Checkkv(err, "read config", "path", path, "user", userID)
+func Checkkv(err error, msg string, kvs ...any) {
+	throw(err, 1, []func(error) error{Fmtkv(msg, kvs...)})
 }
 
 func CheckCleanup(err error, cleanupHandler func()) {
-	Check(err, Cleanup(cleanupHandler))
+	throw(err, 1, []func(error) error{Cleanup(cleanupHandler)})
+}
+
+// Check1 is Check for a function that returns a value and an error.
+// It returns the value so that the result of the function can be used directly:
+//
+//	x := try.Check1(f())
+//
+// If the error is non-nil it is thrown in the same way as Check.
+func Check1[T any](v T, err error) T {
+	if err != nil {
+		throw(err, 1, nil)
+	}
+	return v
+}
+
+// Check2 is Check1 for a function that returns two values and an error.
+func Check2[T any, U any](v1 T, v2 U, err error) (T, U) {
+	if err != nil {
+		throw(err, 1, nil)
+	}
+	return v1, v2
+}
+
+// Check3 is Check1 for a function that returns three values and an error.
+func Check3[T any, U any, V any](v1 T, v2 U, v3 V, err error) (T, U, V) {
+	if err != nil {
+		throw(err, 1, nil)
+	}
+	return v1, v2, v3
+}
+
+// Checkw1 is Checkw for a function that returns a value and an error.
+// Go does not allow additional arguments to be passed along with a multi-value function call,
+// so the annotation is given to the returned function:
+//
+//	x := try.Checkw1(f())("called f with %d", 2)
+func Checkw1[T any](v T, err error) func(format string, args ...interface{}) T {
+	return func(format string, args ...interface{}) T {
+		if err != nil {
+			throw(err, 1, []func(error) error{Fmtw(format, args...)})
+		}
+		return v
+	}
+}
+
+// Checkw2 is Checkw1 for a function that returns two values and an error.
+func Checkw2[T any, U any](v1 T, v2 U, err error) func(format string, args ...interface{}) (T, U) {
+	return func(format string, args ...interface{}) (T, U) {
+		if err != nil {
+			throw(err, 1, []func(error) error{Fmtw(format, args...)})
+		}
+		return v1, v2
+	}
+}
+
+// Checkw3 is Checkw1 for a function that returns three values and an error.
+func Checkw3[T any, U any, V any](v1 T, v2 U, v3 V, err error) func(format string, args ...interface{}) (T, U, V) {
+	return func(format string, args ...interface{}) (T, U, V) {
+		if err != nil {
+			throw(err, 1, []func(error) error{Fmtw(format, args...)})
+		}
+		return v1, v2, v3
+	}
+}
+
+// Checkf1 is Checkf for a function that returns a value and an error.
+//
+//	x := try.Checkf1(f())("called f with %d", 2)
+func Checkf1[T any](v T, err error) func(format string, args ...interface{}) T {
+	return func(format string, args ...interface{}) T {
+		if err != nil {
+			throw(err, 1, []func(error) error{Fmtf(format, args...)})
+		}
+		return v
+	}
+}
+
+// Checkf2 is Checkf1 for a function that returns two values and an error.
+func Checkf2[T any, U any](v1 T, v2 U, err error) func(format string, args ...interface{}) (T, U) {
+	return func(format string, args ...interface{}) (T, U) {
+		if err != nil {
+			throw(err, 1, []func(error) error{Fmtf(format, args...)})
+		}
+		return v1, v2
+	}
+}
+
+// Checkf3 is Checkf1 for a function that returns three values and an error.
+func Checkf3[T any, U any, V any](v1 T, v2 U, v3 V, err error) func(format string, args ...interface{}) (T, U, V) {
+	return func(format string, args ...interface{}) (T, U, V) {
+		if err != nil {
+			throw(err, 1, []func(error) error{Fmtf(format, args...)})
+		}
+		return v1, v2, v3
+	}
 }
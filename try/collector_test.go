@@ -0,0 +1,61 @@
+package try_test
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/gregwebs/try/assert"
+	"github.com/gregwebs/try/handle"
+	"github.com/gregwebs/try/try"
+)
+
+var errRequired = errors.New("required")
+
+func TestCollector(t *testing.T) {
+	validate := func() (err error) {
+		defer handle.Wrap(&err, "validate")
+		c := try.NewCollector()
+		assert.That(!c.Add(nil), "nil is ignored")
+		c.Addw(errRequired, "field %s", "name")
+		addr := c.Path("address")
+		addr.Add(errRequired)
+		addr.Path("zip").Add(io.ErrUnexpectedEOF)
+		c.Check()
+		return nil
+	}
+	err := validate()
+	assert.Equal(err.Error(), "validate: field name: required\naddress: required\naddress.zip: unexpected EOF")
+	assert.That(errors.Is(err, errRequired), "errors.Is finds the collected errors")
+	assert.That(errors.Is(err, io.ErrUnexpectedEOF), "errors.Is finds the collected errors")
+	var fieldErr *try.FieldError
+	assert.That(errors.As(err, &fieldErr), "errors.As finds a FieldError")
+	assert.Equal(fieldErr.Path, "address")
+}
+
+func TestCollector_noErrors(t *testing.T) {
+	c := try.NewCollector()
+	c.Path("a").Add(nil)
+	c.Check()
+	assert.NoError(c.Err())
+	assert.SLen(c.Errors(), 0)
+}
+
+func TestCollector_zeroValue(t *testing.T) {
+	var c try.Collector
+	c.Path("name").Add(errRequired)
+	c.Add(io.ErrUnexpectedEOF)
+	assert.SLen(c.Errors(), 2)
+	assert.That(errors.Is(c.Err(), errRequired), "the errors of Path are collected")
+}
+
+func ExampleCollector() {
+	c := try.NewCollector()
+	c.Addw(errRequired, "name")
+	c.Path("address").Add(errRequired)
+	fmt.Println(c.Err())
+	// Output:
+	// name: required
+	// address: required
+}
@@ -0,0 +1,83 @@
+package try_test
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/gregwebs/try/assert"
+	"github.com/gregwebs/try/handle"
+	"github.com/gregwebs/try/try"
+)
+
+func loadUser(id int, err error) (rerr error) {
+	defer handle.Format(&rerr, "load user %d", id)
+	try.Checkw(err, "query user %d", id)
+	return nil
+}
+
+func saveUser(id int, err error) (rerr error) {
+	defer handle.Wrap(&rerr, "save user %d", id)
+	try.Checkf(err, "update user %d", id)
+	return nil
+}
+
+func TestFingerprint(t *testing.T) {
+	assert.Equal(try.Fingerprint(nil), "")
+
+	fp := try.Fingerprint(loadUser(1, io.EOF))
+	assert.Equal(len(fp), 16)
+	assert.Equal(try.Fingerprint(loadUser(2, io.EOF)), fp, "the formatted values are not used")
+	assert.That(try.Fingerprint(loadUser(1, io.ErrUnexpectedEOF)) != fp, "the sentinel is used")
+	assert.That(try.Fingerprint(saveUser(1, io.EOF)) != fp, "the call site is used")
+	assert.Equal(try.Fingerprint(saveUser(1, io.EOF)), try.Fingerprint(saveUser(2, io.EOF)))
+
+	wrapped := fmt.Errorf("wrapped: %w", loadUser(1, io.EOF))
+	assert.That(try.Fingerprint(wrapped) != fp, "the types in the chain are used")
+	assert.Equal(try.Fingerprint(fmt.Errorf("wrapped again: %w", loadUser(3, io.EOF))), try.Fingerprint(wrapped))
+
+	marked := try.Fingerprint(try.Mark(try.NewSentinel(try.NotFound, "no user"))(io.EOF))
+	assert.That(marked != try.Fingerprint(try.Mark(try.NewSentinel(try.NotFound, "no order"))(io.EOF)), "the mark is used")
+}
+
+func TestFingerprint_errorf(t *testing.T) {
+	assert.Equal(try.Fingerprint(fmt.Errorf("user %d not found", 1)), try.Fingerprint(fmt.Errorf("user %d not found", 2)))
+	assert.Equal(try.Fingerprint(fmt.Errorf("open %s: no such file", "/tmp/a")), try.Fingerprint(fmt.Errorf("open %s: no such file", "/tmp/b")))
+	assert.Equal(try.Fingerprint(fmt.Errorf("no user %q: not found", "ann")), try.Fingerprint(fmt.Errorf("no user %q: not found", "bob smith")))
+	assert.That(try.Fingerprint(errors.New("no user")) != try.Fingerprint(errors.New("no order")), "the words of the message are used")
+}
+
+func TestFingerprintLines(t *testing.T) {
+	defer try.Config.Override(func(c *try.Settings) {
+		c.SetAddStackTrace(true)
+	})()
+	check := func(line int) (err error) {
+		defer handle.Do(&err, nil)
+		if line == 1 {
+			try.Check(io.EOF)
+		} else {
+			try.Check(io.EOF)
+		}
+		return nil
+	}
+	assert.Equal(try.Fingerprint(check(1)), try.Fingerprint(check(2)))
+	assert.That(try.FingerprintLines(check(1)) != try.FingerprintLines(check(2)), "the lines are used")
+	assert.Equal(try.FingerprintLines(check(1)), try.FingerprintLines(check(1)))
+}
+
+func TestFingerprint_panic(t *testing.T) {
+	index := func(i int) (err error) {
+		defer func() {
+			err = recover().(handle.PanicAnnotated)
+		}()
+		defer handle.Wrap(&err, "index %d", i)
+		return []error{nil}[i]
+	}
+	fp := try.Fingerprint(index(2))
+	assert.Equal(try.Fingerprint(index(3)), fp, "the panic message is not used")
+
+	panicked := handle.PanicAnnotated{Panic: "boom", Err: errors.New("boom")}
+	assert.That(try.Fingerprint(panicked) != try.Fingerprint(handle.PanicAnnotated{Panic: errors.New("boom"), Err: errors.New("boom")}), "the panic type is used")
+	assert.Equal(try.Fingerprint(panicked), try.Fingerprint(handle.PanicAnnotated{Panic: "bang", Err: errors.New("bang")}))
+}
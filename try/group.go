@@ -0,0 +1,152 @@
+package try
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"sync"
+
+	"github.com/gregwebs/try/internal/panics"
+	"github.com/gregwebs/try/internal/stack"
+)
+
+// Group runs functions in goroutines and collects their errors, similar to golang.org/x/sync/errgroup.
+// The functions may use try.Check without deferring a handler:
+// a thrown error is recovered and returned from Wait.
+// A panic is recovered as well and returned as a PanicAnnotated error,
+// so that a goroutine cannot crash the process.
+//
+// The zero value is ready to use. It does not limit concurrency and has no context.
+type Group struct {
+	cancel func(error)
+	wg     sync.WaitGroup
+	sem    chan struct{}
+
+	mu   sync.Mutex
+	errs []error
+}
+
+// NewGroup creates a Group with a context that is canceled when a function returns an error or when Wait returns.
+// The cause of the cancellation given by context.Cause is the first error.
+func NewGroup(ctx context.Context) (*Group, context.Context) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	return &Group{cancel: cancel}, ctx
+}
+
+// SetLimit limits the number of goroutines that are running at the same time.
+// Go blocks until a goroutine can be started.
+// A negative limit removes the limit.
+// SetLimit must not be called while goroutines are running.
+func (g *Group) SetLimit(n int) {
+	if n < 0 {
+		g.sem = nil
+		return
+	}
+	if len(g.sem) != 0 {
+		panic(fmt.Errorf("try: SetLimit(%d) called while %d goroutines are running", n, len(g.sem)))
+	}
+	g.sem = make(chan struct{}, n)
+}
+
+// Go runs the function in a new goroutine.
+// An error records the place where Go was called so that it can be found in the result of Wait.
+func (g *Group) Go(fn func() error) {
+	site := spawnSite()
+	if g.sem != nil {
+		g.sem <- struct{}{}
+	}
+	g.wg.Add(1)
+	go func() {
+		defer g.done()
+		if err := catchAll(fn); err != nil {
+			g.fail(&GoroutineError{Site: site, Err: err})
+		}
+	}()
+}
+
+func (g *Group) done() {
+	if g.sem != nil {
+		<-g.sem
+	}
+	g.wg.Done()
+}
+
+func (g *Group) fail(err error) {
+	g.mu.Lock()
+	g.errs = append(g.errs, err)
+	g.mu.Unlock()
+	if g.cancel != nil {
+		g.cancel(err)
+	}
+}
+
+// Wait waits for all of the goroutines to finish.
+// It returns the errors of the goroutines joined with errors.Join, or nil if there were no errors.
+func (g *Group) Wait() error {
+	g.wg.Wait()
+	if g.cancel != nil {
+		g.cancel(nil)
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return stderrors.Join(g.errs...)
+}
+
+// catchAll runs the function and recovers both thrown errors and panics.
+func catchAll(fn func() error) (err error) {
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		}
+		if err = panics.Thrown(r); err != nil {
+			return
+		}
+		if annotated, ok := r.(panics.Annotated); ok {
+			err = annotated
+			return
+		}
+		err = panics.Annotated{Panic: r, Err: stack.New(fmt.Errorf("%+v", r), 0), PanicStack: panics.Stack(0)}
+	}()
+	return fn()
+}
+
+// GoroutineError is an error from a goroutine started by Group.Go.
+// Site is the place where Go was called.
+type GoroutineError struct {
+	Site runtime.Frame
+	Err  error
+}
+
+func (e *GoroutineError) Error() string {
+	return fmt.Sprintf("goroutine started at %s:%d: %v", filepath.Base(e.Site.File), e.Site.Line, e.Err)
+}
+
+func (e *GoroutineError) Unwrap() error { return e.Err }
+
+func (e *GoroutineError) Format(s fmt.State, verb rune) {
+	switch verb {
+	case 'v':
+		if s.Flag('+') {
+			fmt.Fprintf(s, "%+v\ngoroutine started at %s\n\t%s:%d", e.Err, e.Site.Function, e.Site.File, e.Site.Line)
+			return
+		}
+		fallthrough
+	case 's':
+		fmt.Fprint(s, e.Error())
+	case 'q':
+		fmt.Fprintf(s, "%q", e.Error())
+	}
+}
+
+// spawnSite gives the caller of Group.Go
+func spawnSite() runtime.Frame {
+	var pcs [1]uintptr
+	if runtime.Callers(3, pcs[:]) == 0 {
+		return runtime.Frame{}
+	}
+	frame, _ := runtime.CallersFrames(pcs[:]).Next()
+	return frame
+}
@@ -0,0 +1,75 @@
+package try_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gregwebs/try/assert"
+	"github.com/gregwebs/try/handle"
+	"github.com/gregwebs/try/try"
+)
+
+func TestGroup_errors(t *testing.T) {
+	g, ctx := try.NewGroup(context.Background())
+	g.Go(func() error {
+		try.Checkw(io.EOF, "thrown")
+		return nil
+	})
+	g.Go(func() error {
+		var b []byte
+		b[0] = 0
+		return nil
+	})
+	g.Go(func() error { return nil })
+	err := g.Wait()
+
+	assert.That(errors.Is(err, io.EOF), "thrown errors are returned")
+	var panicked handle.PanicAnnotated
+	assert.That(errors.As(err, &panicked), "panics are returned as PanicAnnotated")
+	assert.That(strings.Contains(panicked.Error(), "index out of range"), panicked.Error())
+
+	var goErr *try.GoroutineError
+	assert.That(errors.As(err, &goErr), "errors record the spawn site")
+	assert.That(strings.HasSuffix(goErr.Site.File, "group_test.go"), goErr.Site.File)
+	assert.That(strings.Contains(err.Error(), "goroutine started at group_test.go:"), err.Error())
+
+	assert.That(errors.Is(context.Cause(ctx), io.EOF) || errors.As(context.Cause(ctx), &panicked), "the context is canceled with the first error")
+}
+
+func TestGroup_handledPanic(t *testing.T) {
+	var g try.Group
+	g.Go(func() (err error) {
+		defer handle.Wrap(&err, "annotated")
+		panic("boom")
+	})
+	err := g.Wait()
+	var panicked handle.PanicAnnotated
+	assert.That(errors.As(err, &panicked), "expected PanicAnnotated")
+	assert.That(panicked.Panic == "boom", "the panic value is kept")
+	assert.Equal(panicked.Err.Error(), "annotated: boom")
+}
+
+func TestGroup_limit(t *testing.T) {
+	var g try.Group
+	g.SetLimit(2)
+	var running, maxRunning atomic.Int32
+	for i := 0; i < 10; i++ {
+		g.Go(func() error {
+			n := running.Add(1)
+			for {
+				m := maxRunning.Load()
+				if n <= m || maxRunning.CompareAndSwap(m, n) {
+					break
+				}
+			}
+			running.Add(-1)
+			return nil
+		})
+	}
+	assert.NoError(g.Wait())
+	assert.That(maxRunning.Load() <= 2, "the limit is respected")
+}
@@ -0,0 +1,119 @@
+package try
+
+import (
+	stderrors "errors"
+	"fmt"
+
+	"github.com/gregwebs/try/internal/kind"
+)
+
+// This file contains combinators for building error handlers.
+// An error handler has the type func(error) error.
+// Error handlers can be given to try.Check or to the Handle* functions.
+//
+//	defer try.Handle(&err, try.Chain(
+//		try.IfIs(sql.ErrNoRows, func(err error) error { return ErrNotFound }),
+//		try.Fmtw("lookup user %d", id),
+//	))
+
+// When creates an error handler that only applies the handler when the predicate is true.
+func When(pred func(error) bool, handler func(error) error) func(error) error {
+	return func(err error) error {
+		if pred(err) {
+			return handler(err)
+		}
+		return err
+	}
+}
+
+// IfIs creates an error handler that only applies the handler when errors.Is(err, target).
+func IfIs(target error, handler func(error) error) func(error) error {
+	return When(func(err error) bool {
+		return stderrors.Is(err, target)
+	}, handler)
+}
+
+// IfAs creates an error handler that applies the handler to the first error in the chain that is a T.
+// The error is returned unchanged if there is no T in the chain.
+//
+//	try.IfAs(func(err *fs.PathError) error {
+//		return fmt.Errorf("bad path %s: %w", err.Path, err)
+//	})
+func IfAs[T error](handler func(T) error) func(error) error {
+	return func(err error) error {
+		var target T
+		if stderrors.As(err, &target) {
+			return handler(target)
+		}
+		return err
+	}
+}
+
+// Chain combines error handlers into a single error handler.
+// The handlers are applied in order in the same way that try.Check applies them:
+// a handler that returns nil leaves the error unchanged.
+func Chain(handlers ...func(error) error) func(error) error {
+	return func(err error) error {
+		for _, handler := range handlers {
+			if handler == nil {
+				continue
+			}
+			if errHandled := handler(err); errHandled != nil {
+				err = errHandled
+			}
+		}
+		return err
+	}
+}
+
+// Replace creates an error handler that returns newErr when errors.Is(err, target).
+// Otherwise the error is returned unchanged.
+func Replace(target error, newErr error) func(error) error {
+	return IfIs(target, func(error) error {
+		return newErr
+	})
+}
+
+// Mark creates an error handler that marks the error with a sentinel error.
+// The resulting error will satisfy errors.Is(err, sentinel).
+// The message and the rest of the chain of the original error are preserved.
+// If the sentinel was created with NewSentinel, the error has the Kind of the sentinel.
+func Mark(sentinel error) func(error) error {
+	return func(err error) error {
+		return &marked{err: err, sentinel: sentinel}
+	}
+}
+
+type marked struct {
+	err      error
+	sentinel error
+}
+
+func (m *marked) Error() string { return m.err.Error() }
+func (m *marked) Unwrap() error { return m.err }
+
+// Is delegates to errors.Is so that a sentinel that is not comparable does not panic
+// and the Kind of a sentinel created with NewSentinel matches.
+func (m *marked) Is(target error) bool {
+	return stderrors.Is(m.sentinel, target)
+}
+
+// ErrorKind gives the Kind of the sentinel
+func (m *marked) ErrorKind() kind.Kind {
+	return kind.Of(m.sentinel)
+}
+
+func (m *marked) Format(s fmt.State, verb rune) {
+	switch verb {
+	case 'v':
+		if s.Flag('+') {
+			fmt.Fprintf(s, "%+v", m.err)
+			return
+		}
+		fallthrough
+	case 's':
+		fmt.Fprint(s, m.err.Error())
+	case 'q':
+		fmt.Fprintf(s, "%q", m.err.Error())
+	}
+}
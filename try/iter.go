@@ -0,0 +1,66 @@
+//go:build go1.23
+
+package try
+
+import (
+	"bufio"
+	"io"
+	"iter"
+)
+
+// Values adapts a sequence of values and errors to a sequence of values.
+// The first error is thrown with Check, applying the handlers.
+//
+//	for row := range try.Values(rows.All()) {
+//		...
+//	}
+func Values[T any](seq iter.Seq2[T, error], handlers ...func(error) error) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		// The error is thrown outside of the loop body so that the stack trace starts at the caller
+		var err error
+		for v, e := range seq {
+			if e != nil {
+				err = e
+				break
+			}
+			if !yield(v) {
+				return
+			}
+		}
+		throw(err, 1, handlers)
+	}
+}
+
+// Collect gives the values of a sequence of values and errors.
+// The first error is thrown with Check, applying the handlers.
+func Collect[T any](seq iter.Seq2[T, error], handlers ...func(error) error) []T {
+	var values []T
+	var err error
+	for v, e := range seq {
+		if e != nil {
+			err = e
+			break
+		}
+		values = append(values, v)
+	}
+	throw(err, 1, handlers)
+	return values
+}
+
+// Lines is a sequence of the lines of a reader, using bufio.Scanner.
+// The error from the Scanner is thrown with Check after the last line, applying the handlers.
+//
+//	for line := range try.Lines(f) {
+//		...
+//	}
+func Lines(r io.Reader, handlers ...func(error) error) iter.Seq[string] {
+	return func(yield func(string) bool) {
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			if !yield(scanner.Text()) {
+				return
+			}
+		}
+		throw(scanner.Err(), 1, handlers)
+	}
+}
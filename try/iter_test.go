@@ -0,0 +1,102 @@
+//go:build go1.23
+
+package try_test
+
+import (
+	"errors"
+	"io"
+	"iter"
+	"runtime"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/gregwebs/try/assert"
+	"github.com/gregwebs/try/handle"
+	"github.com/gregwebs/try/try"
+)
+
+var errRow = errors.New("bad row")
+
+func rows(n int, failAt int) iter.Seq2[int, error] {
+	return func(yield func(int, error) bool) {
+		for i := 0; i < n; i++ {
+			if i == failAt {
+				yield(0, errRow)
+				return
+			}
+			if !yield(i, nil) {
+				return
+			}
+		}
+	}
+}
+
+func TestValues(t *testing.T) {
+	sum := func(seq iter.Seq2[int, error]) (total int, err error) {
+		defer handle.Wrap(&err, "sum")
+		for v := range try.Values(seq) {
+			total += v
+		}
+		return total, nil
+	}
+	total, err := sum(rows(4, -1))
+	assert.NoError(err)
+	assert.Equal(total, 6)
+
+	_, err = sum(rows(4, 2))
+	assert.That(errors.Is(err, errRow), "the error is thrown")
+	assert.Equal(err.Error(), "sum: bad row")
+	assert.That(strings.HasSuffix(firstFrame(err), "TestValues.func1"), "the stack starts at the caller: "+firstFrame(err))
+
+	var seen []int
+	for v := range try.Values(rows(4, 3)) {
+		seen = append(seen, v)
+		if v == 1 {
+			break
+		}
+	}
+	assert.SLen(seen, 2)
+}
+
+func TestCollect(t *testing.T) {
+	assert.SLen(try.Collect(rows(3, -1)), 3)
+
+	collect := func() (err error) {
+		defer handle.Do(&err, nil)
+		try.Collect(rows(3, 1), try.Fmtw("collect"))
+		return nil
+	}
+	err := collect()
+	assert.Equal(err.Error(), "collect: bad row")
+	assert.That(strings.HasSuffix(firstFrame(err), "TestCollect.func1"), "the stack starts at the caller: "+firstFrame(err))
+}
+
+func TestLines(t *testing.T) {
+	var lines []string
+	for line := range try.Lines(strings.NewReader("a\nb\nc")) {
+		lines = append(lines, line)
+	}
+	assert.Equal(strings.Join(lines, ","), "a,b,c")
+
+	readAll := func(r io.Reader) (n int, err error) {
+		defer handle.Do(&err, nil)
+		for range try.Lines(r) {
+			n++
+		}
+		return n, nil
+	}
+	n, err := readAll(iotest.TimeoutReader(strings.NewReader("a\nb\n")))
+	assert.Equal(n, 2)
+	assert.That(errors.Is(err, iotest.ErrTimeout), "the scanner error is thrown")
+	assert.That(strings.HasSuffix(firstFrame(err), "TestLines.func1"), "the stack starts at the caller: "+firstFrame(err))
+}
+
+// firstFrame gives the function of the first frame of the stack trace
+func firstFrame(err error) string {
+	var tracer interface{ Frames() []runtime.Frame }
+	if !errors.As(err, &tracer) || len(tracer.Frames()) == 0 {
+		return ""
+	}
+	return tracer.Frames()[0].Function
+}
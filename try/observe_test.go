@@ -0,0 +1,103 @@
+package try_test
+
+import (
+	"encoding/json"
+	"expvar"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/gregwebs/try/assert"
+	"github.com/gregwebs/try/handle"
+	"github.com/gregwebs/try/try"
+)
+
+func throwEOF() (err error) {
+	defer handle.Do(&err, nil)
+	try.Checkw(io.EOF, "read")
+	return nil
+}
+
+func panicTwice() (err error) {
+	defer handle.Do(&err, nil)
+	func() (err error) {
+		defer handle.Do(&err, nil)
+		panic("boom")
+	}()
+	return nil
+}
+
+func TestOnThrow(t *testing.T) {
+	var events []try.ThrowEvent
+	remove := try.OnThrow(func(e try.ThrowEvent) {
+		events = append(events, e)
+	})
+	assert.NoError(func() (err error) {
+		defer handle.Do(&err, nil)
+		try.Check(nil)
+		return nil
+	}())
+	assert.That(throwEOF() != nil, "expected an error")
+	remove()
+	assert.That(throwEOF() != nil, "expected an error")
+
+	assert.SLen(events, 1)
+	assert.Equal(events[0].Err.Error(), "read: EOF")
+	assert.That(strings.HasSuffix(events[0].Site.Function, "try_test.throwEOF"), events[0].Site.Function)
+	assert.That(strings.HasSuffix(events[0].Site.File, "observe_test.go"), events[0].Site.File)
+}
+
+func TestOnRecover(t *testing.T) {
+	var events []handle.RecoverEvent
+	remove := handle.OnRecover(func(e handle.RecoverEvent) {
+		events = append(events, e)
+	})
+	defer remove()
+
+	assert.That(throwEOF() != nil, "expected an error")
+	assert.SLen(events, 1)
+	assert.Equal(events[0].Kind, handle.RecoverError)
+	assert.Equal(events[0].Err.Error(), "read: EOF")
+	assert.That(strings.HasSuffix(events[0].Site.Function, "try_test.throwEOF"), events[0].Site.Function)
+
+	events = nil
+	func() {
+		defer func() { _ = recover() }()
+		_ = panicTwice()
+	}()
+	assert.SLen(events, 2)
+	assert.Equal(events[0].Kind, handle.RecoverPanic)
+	assert.Equal(events[1].Kind, handle.RecoverAnnotated)
+	assert.That(events[1].Panic == "boom", "the panic value is given")
+}
+
+func TestSiteCounter(t *testing.T) {
+	counter := try.NewSiteCounter()
+	var _ expvar.Var = counter
+	for i := 0; i < 3; i++ {
+		_ = throwEOF()
+	}
+	counter.Stop()
+	_ = throwEOF()
+
+	counts := counter.Counts()
+	assert.SLen(counts, 2)
+	assert.Equal(counts[0].Event, "throw")
+	assert.Equal(counts[0].Count, uint64(3))
+	assert.Equal(counts[0].File, "observe_test.go")
+	assert.Equal(counts[1].Event, "recover")
+	assert.Equal(counts[1].Kind, "error")
+	assert.Equal(counts[1].Count, uint64(3))
+
+	var decoded []try.SiteCount
+	assert.NoError(json.Unmarshal([]byte(counter.String()), &decoded))
+	assert.SLen(decoded, 2)
+
+	var b strings.Builder
+	assert.NoError(counter.WritePrometheus(&b))
+	prom := b.String()
+	assert.That(strings.Contains(prom, "# TYPE try_throws_total counter\n"), prom)
+	assert.That(strings.Contains(prom, `try_throws_total{function="github.com/gregwebs/try/try_test.throwEOF",file="observe_test.go",line="`), prom)
+	assert.That(strings.Contains(prom, `try_recovers_total{kind="error",function="github.com/gregwebs/try/try_test.throwEOF"`), prom)
+	assert.That(strings.Contains(prom, "} 3\n"), prom)
+}
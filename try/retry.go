@@ -0,0 +1,208 @@
+package try
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/gregwebs/try/internal/panics"
+)
+
+// Policy configures Retry.
+//
+// An error is retried when it is marked with Retryable, matches one of RetryOn with errors.Is,
+// or RetryIf returns true for it. Any other error stops retrying immediately.
+//
+// The delay before the nth retry is InitialDelay * Multiplier^(n-1), limited to MaxDelay.
+// Jitter randomizes each delay by up to that fraction of the delay in either direction.
+//
+// The zero values of MaxAttempts, InitialDelay, MaxDelay, and Multiplier are replaced by the initial values of DefaultPolicy
+// so that the zero Policy does not retry in a hot loop. A zero Jitter means no jitter.
+type Policy struct {
+	// MaxAttempts is the maximum number of calls, including the first one.
+	// 0 means 5 unless MaxElapsed is set.
+	// A negative value means no limit: the attempts are then limited by MaxElapsed or the context.
+	MaxAttempts int
+	// MaxElapsed is the maximum time from the first call after which no new attempt is started.
+	// 0 means no limit.
+	MaxElapsed time.Duration
+
+	// InitialDelay is the delay before the first retry. 0 means 100ms.
+	InitialDelay time.Duration
+	// MaxDelay limits the delay between attempts. 0 means 10s.
+	MaxDelay time.Duration
+	// Multiplier is the growth of the delay between attempts. 0 means 2.
+	Multiplier float64
+	// Jitter is between 0 and 1. 0 means no jitter.
+	Jitter float64
+
+	RetryOn []error
+	RetryIf func(error) bool
+}
+
+// The defaults of a Policy. They are constants rather than read from DefaultPolicy, which can be changed.
+const (
+	defaultMaxAttempts  = 5
+	defaultInitialDelay = 100 * time.Millisecond
+	defaultMaxDelay     = 10 * time.Second
+	defaultMultiplier   = 2
+)
+
+// DefaultPolicy makes at most 5 attempts, so it retries Retryable errors up to 4 times,
+// with exponential backoff starting at 100ms.
+var DefaultPolicy = Policy{
+	MaxAttempts:  defaultMaxAttempts,
+	InitialDelay: defaultInitialDelay,
+	MaxDelay:     defaultMaxDelay,
+	Multiplier:   defaultMultiplier,
+	Jitter:       0.2,
+}
+
+func (p Policy) retryable(err error) bool {
+	if IsRetryable(err) || isAny(err, p.RetryOn) {
+		return true
+	}
+	return p.RetryIf != nil && p.RetryIf(err)
+}
+
+// withDefaults replaces the zero values with the defaults
+func (p Policy) withDefaults() Policy {
+	if p.MaxAttempts == 0 && p.MaxElapsed <= 0 {
+		p.MaxAttempts = defaultMaxAttempts
+	}
+	if p.InitialDelay <= 0 {
+		p.InitialDelay = defaultInitialDelay
+	}
+	if p.MaxDelay <= 0 {
+		p.MaxDelay = defaultMaxDelay
+	}
+	if p.Multiplier == 0 {
+		p.Multiplier = defaultMultiplier
+	}
+	p.Jitter = math.Min(math.Max(p.Jitter, 0), 1)
+	return p
+}
+
+// Delay gives the delay before the nth retry, starting at 1.
+// The delay is always limited to MaxDelay: it saturates rather than overflowing for a large retry count.
+func (p Policy) Delay(retry int) time.Duration {
+	p = p.withDefaults()
+	maxDelay := float64(p.MaxDelay)
+	d := float64(p.InitialDelay) * math.Pow(p.Multiplier, float64(retry-1))
+	if math.IsNaN(d) || d > maxDelay {
+		d = maxDelay
+	}
+	if p.Jitter > 0 {
+		d += (rand.Float64()*2 - 1) * p.Jitter * d
+	}
+	return time.Duration(math.Min(math.Max(d, 0), maxDelay))
+}
+
+// Retryable marks an error so that Retry will retry it.
+//
+//	try.Check(err, try.Retryable)
+func Retryable(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &retryable{err}
+}
+
+// IsRetryable tells whether an error in the chain was marked with Retryable
+func IsRetryable(err error) bool {
+	var r *retryable
+	return stderrors.As(err, &r)
+}
+
+type retryable struct{ error }
+
+func (r *retryable) Unwrap() error { return r.error }
+
+func (r *retryable) Format(s fmt.State, verb rune) {
+	fmt.Fprintf(s, fmt.FormatString(s, verb), r.error)
+}
+
+// RetryError is returned by Retry when it gives up
+type RetryError struct {
+	Attempts int
+	// Err is the error from the last attempt
+	Err error
+	// Stopped is the context error when the context ended while waiting to retry
+	Stopped error
+}
+
+func (e *RetryError) Error() string {
+	attempts := "attempts"
+	if e.Attempts == 1 {
+		attempts = "attempt"
+	}
+	if e.Stopped != nil {
+		return fmt.Sprintf("%v after %d %s: %v", e.Stopped, e.Attempts, attempts, e.Err)
+	}
+	return fmt.Sprintf("after %d %s: %v", e.Attempts, attempts, e.Err)
+}
+
+func (e *RetryError) Unwrap() []error {
+	if e.Stopped != nil {
+		return []error{e.Err, e.Stopped}
+	}
+	return []error{e.Err}
+}
+
+// Retry calls fn until it succeeds or the policy stops retrying.
+// fn may use try.Check: a thrown error is recovered for each attempt in the same way as handle.Do.
+// Panics are not recovered.
+//
+// If all attempts fail the result is a *RetryError with the attempt count and the last error.
+// An error that is not retryable is returned as is when it is given by the first attempt.
+// If the context is already done, fn is not called and the error is a ContextError.
+func Retry(ctx context.Context, policy Policy, fn func() error) error {
+	_, err := Retry1(ctx, policy, func() (struct{}, error) {
+		return struct{}{}, fn()
+	})
+	return err
+}
+
+// Retry1 is Retry for a function that returns a value.
+func Retry1[T any](ctx context.Context, policy Policy, fn func() (T, error)) (T, error) {
+	if ctx.Err() != nil {
+		var zero T
+		return zero, CtxErr(ctx)
+	}
+	policy = policy.withDefaults()
+	start := time.Now()
+	for attempt := 1; ; attempt++ {
+		var v T
+		err := panics.Catch(func() (err error) {
+			v, err = fn()
+			return err
+		})
+		if err == nil {
+			return v, nil
+		}
+		if !policy.retryable(err) {
+			if attempt == 1 {
+				return v, err
+			}
+			return v, &RetryError{Attempts: attempt, Err: err}
+		}
+		if policy.MaxAttempts > 0 && attempt >= policy.MaxAttempts {
+			return v, &RetryError{Attempts: attempt, Err: err}
+		}
+		delay := policy.Delay(attempt)
+		if policy.MaxElapsed > 0 && time.Since(start)+delay > policy.MaxElapsed {
+			return v, &RetryError{Attempts: attempt, Err: err}
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return v, &RetryError{Attempts: attempt, Err: err, Stopped: ctx.Err()}
+		case <-timer.C:
+		}
+	}
+}
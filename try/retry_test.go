@@ -0,0 +1,137 @@
+package try_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gregwebs/try/assert"
+	"github.com/gregwebs/try/try"
+)
+
+var fastPolicy = try.Policy{MaxAttempts: 3, InitialDelay: time.Millisecond, Jitter: 0.5}
+
+func TestRetry_Check(t *testing.T) {
+	calls := 0
+	n, err := try.Retry1(context.Background(), fastPolicy, func() (int, error) {
+		calls++
+		if calls < 3 {
+			try.Check(io.ErrUnexpectedEOF, try.Retryable)
+		}
+		return try.Check1(strconv.Atoi("3")), nil
+	})
+	assert.NoError(err)
+	assert.Equal(n, 3)
+	assert.Equal(calls, 3)
+}
+
+func TestRetry_attempts(t *testing.T) {
+	calls := 0
+	policy := fastPolicy
+	policy.RetryOn = []error{io.EOF}
+	err := try.Retry(context.Background(), policy, func() error {
+		calls++
+		try.Checkw(io.EOF, "read %d", calls)
+		return nil
+	})
+	assert.Equal(calls, 3)
+	var retryErr *try.RetryError
+	assert.That(errors.As(err, &retryErr), "expected a RetryError")
+	assert.Equal(retryErr.Attempts, 3)
+	assert.Equal(err.Error(), "after 3 attempts: read 3: EOF")
+	assert.That(errors.Is(err, io.EOF), "the last cause is in the chain")
+}
+
+func TestRetry_notRetryable(t *testing.T) {
+	calls := 0
+	err := try.Retry(context.Background(), fastPolicy, func() error {
+		calls++
+		return io.EOF
+	})
+	assert.Equal(calls, 1)
+	assert.That(err == io.EOF, "a non-retryable error is returned as is")
+}
+
+func TestRetry_context(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	policy := try.Policy{InitialDelay: time.Hour, RetryIf: func(error) bool { return true }}
+	err := try.Retry(ctx, policy, func() error {
+		cancel()
+		return io.EOF
+	})
+	assert.That(errors.Is(err, context.Canceled), "the context error is in the chain")
+	assert.That(errors.Is(err, io.EOF), "the last cause is in the chain")
+}
+
+func TestRetry_maxElapsed(t *testing.T) {
+	calls := 0
+	policy := try.Policy{InitialDelay: 50 * time.Millisecond, MaxElapsed: 10 * time.Millisecond, RetryOn: []error{io.EOF}}
+	err := try.Retry(context.Background(), policy, func() error {
+		calls++
+		return io.EOF
+	})
+	assert.Equal(calls, 1)
+	assert.Equal(err.Error(), "after 1 attempt: EOF")
+}
+
+func TestRetry_zeroPolicy(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 150*time.Millisecond)
+	defer cancel()
+	calls := 0
+	err := try.Retry(ctx, try.Policy{RetryOn: []error{io.EOF}}, func() error {
+		calls++
+		return io.EOF
+	})
+	assert.Equal(calls, 2, "the zero Policy waits DefaultPolicy.InitialDelay and doubles it")
+	assert.That(errors.Is(err, context.DeadlineExceeded), err.Error())
+
+	calls = 0
+	err = try.Retry(ctx, try.Policy{}, func() error {
+		calls++
+		return nil
+	})
+	assert.That(errors.Is(err, context.DeadlineExceeded), "a done context is checked before the first attempt")
+	assert.Equal(calls, 0)
+}
+
+func TestPolicy_Delay(t *testing.T) {
+	assert.Equal(try.Policy{}.Delay(1), try.DefaultPolicy.InitialDelay)
+	assert.Equal(try.Policy{}.Delay(2), 2*try.DefaultPolicy.InitialDelay)
+	for _, retry := range []int{64, 1100, math.MaxInt} {
+		assert.Equal(try.Policy{}.Delay(retry), try.DefaultPolicy.MaxDelay, "the delay saturates at MaxDelay")
+		policy := try.Policy{InitialDelay: time.Second, MaxDelay: time.Minute, Multiplier: 10, Jitter: 1}
+		delay := policy.Delay(retry)
+		assert.That(delay >= 0 && delay <= time.Minute, delay.String())
+	}
+
+	defer func(saved try.Policy) { try.DefaultPolicy = saved }(try.DefaultPolicy)
+	try.DefaultPolicy.InitialDelay = time.Hour
+	assert.Equal(try.Policy{}.Delay(1), 100*time.Millisecond, "the defaults do not change with DefaultPolicy")
+}
+
+func TestRetry_panic(t *testing.T) {
+	defer func() {
+		assert.That(recover() != nil, "panics are not recovered")
+	}()
+	_ = try.Retry(context.Background(), fastPolicy, func() error {
+		var b []byte
+		b[0] = 0
+		return nil
+	})
+}
+
+func ExampleRetry() {
+	attempts := 0
+	err := try.Retry(context.Background(), try.Policy{MaxAttempts: 2}, func() error {
+		attempts++
+		try.Check(fmt.Errorf("unavailable"), try.Retryable)
+		return nil
+	})
+	fmt.Println(err)
+	// Output: after 2 attempts: unavailable
+}
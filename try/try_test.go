@@ -3,12 +3,26 @@ package try_test
 import (
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
+	"strconv"
+	"testing"
 
 	"github.com/gregwebs/try/handle"
 	"github.com/gregwebs/try/try"
 )
 
+// TestMain runs the tests with the default settings rather than the TRY_* environment variables
+func TestMain(m *testing.M) {
+	try.Config.Override(func(c *try.Settings) {
+		c.SetAddStackTrace(true)
+		c.SetLazyStack(false)
+		c.SetStackDepth(32)
+		c.SetAnnotatePanics(true)
+	})
+	os.Exit(m.Run())
+}
+
 func Example_copyFile() {
 	copyFile := func(src, dst string) (err error) {
 		defer handle.Format(&err, "copy %s %s", src, dst)
@@ -25,7 +39,7 @@ func Example_copyFile() {
 		defer handle.Cleanup(&err, func() {
 			os.Remove(dst)
 		})
-		defer w.Close()
+		defer handle.Close(&err, w)
 		_, err = io.Copy(w, r)
 		try.Check(err)
 		return nil
@@ -54,7 +68,7 @@ func Example_copyFile_try() {
 			os.Remove(dst)
 			return err
 		})
-		defer w.Close()
+		defer handle.Close(&err, w)
 		_, err = io.Copy(w, r)
 		try.Check(err)
 		return nil
@@ -66,3 +80,94 @@ func Example_copyFile_try() {
 	}
 	// Output: copy /notfound/path/file.go /notfound/path/file.bak: open /notfound/path/file.go: no such file or directory
 }
+
+func ExampleCheck1() {
+	parse := func(s string) (n int, err error) {
+		defer handle.Wrap(&err, "parse")
+		return try.Check1(strconv.Atoi(s)), nil
+	}
+
+	n, err := parse("12")
+	fmt.Println(n, err)
+	_, err = parse("x")
+	fmt.Println(err)
+	// Output:
+	// 12 <nil>
+	// parse: strconv.Atoi: parsing "x": invalid syntax
+}
+
+func ExampleCheckw1() {
+	parse := func(s string) (n int, err error) {
+		defer handle.Do(&err, nil)
+		return try.Checkw1(strconv.Atoi(s))("parse %s", s), nil
+	}
+
+	_, err := parse("x")
+	fmt.Println(err)
+	// Output: parse x: strconv.Atoi: parsing "x": invalid syntax
+}
+
+func Example_copyFile_tryCleanup() {
+	copyFile := func(src, dst string) (err error) {
+		defer handle.Format(&err, "copy %s %s", src, dst)
+
+		r, err := os.Open(src)
+		try.Check(err)
+		defer r.Close()
+
+		w, err := os.Create(dst)
+		try.Try(err, try.Cleanup(func() {
+			os.Remove(dst)
+		}))
+		defer handle.Close(&err, w)
+
+		_, err = io.Copy(w, r)
+		try.Check(err)
+		return nil
+	}
+
+	err := copyFile("/notfound/path/file.go", "/notfound/path/file.bak")
+	if err != nil {
+		fmt.Println(err)
+	}
+	// Output: copy /notfound/path/file.go /notfound/path/file.bak: open /notfound/path/file.go: no such file or directory
+}
+
+func ExampleTry1() {
+	parse := func(s string) (n int, err error) {
+		defer handle.Do(&err, nil)
+		return try.Try1(strconv.Atoi(s))(try.Fmtf("parse %s", s)), nil
+	}
+
+	_, err := parse("x")
+	fmt.Println(err)
+	// Output: parse x: strconv.Atoi: parsing "x": invalid syntax
+}
+
+func ExampleOr() {
+	readConfig := func(path string) (b []byte, err error) {
+		defer handle.Do(&err, nil)
+		b, err = os.ReadFile(path)
+		return try.Or(b, err, []byte("default"), fs.ErrNotExist), nil
+	}
+
+	b, err := readConfig("/notfound/path/config")
+	fmt.Println(string(b), err)
+	// Output: default <nil>
+}
+
+func ExampleCheckkv() {
+	load := func(path string) (err error) {
+		defer handle.WrapKV(&err, "load", "user", 42)
+		_, err = os.Open(path)
+		try.Checkkv(err, "open config", "path", path)
+		return nil
+	}
+
+	err := load("/notfound/path/config")
+	fmt.Println(err)
+	fmt.Println(try.Fields(err))
+	// Output:
+	// load: open config: open /notfound/path/config: no such file or directory
+	// map[path:/notfound/path/config user:42]
+}
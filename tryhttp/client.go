@@ -0,0 +1,141 @@
+package tryhttp
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gregwebs/try"
+)
+
+// MaxBodyExcerpt is the maximum number of bytes of the response body kept in a ResponseError
+const MaxBodyExcerpt = 512
+
+// maxDrain is the maximum number of bytes read from an error response so that the connection can be reused
+const maxDrain = 64 << 10
+
+// ResponseError is thrown by CheckResponse for a response with a non-2xx status
+type ResponseError struct {
+	Method string
+	// URL is the request URL with the user information removed and the query values redacted
+	URL        string
+	StatusCode int
+	Status     string
+	// Body is an excerpt of at most MaxBodyExcerpt bytes of the response body
+	Body string
+}
+
+func (e *ResponseError) Error() string {
+	msg := fmt.Sprintf("%s %s: %s", e.Method, e.URL, e.Status)
+	if e.Body != "" {
+		msg += ": " + e.Body
+	}
+	return msg
+}
+
+func (e *ResponseError) Format(s fmt.State, verb rune) {
+	switch verb {
+	case 'v', 's':
+		io.WriteString(s, e.Error())
+	case 'q':
+		fmt.Fprintf(s, "%q", e.Error())
+	}
+}
+
+// CheckResponse throws the error from an HTTP request, or a *ResponseError if the status is not 2xx.
+// It is used with the results of an http.Client:
+//
+//	resp := tryhttp.CheckResponse(client.Get(url))
+//	defer resp.Body.Close()
+//
+// The body of an error response is read for the excerpt and closed.
+// Otherwise the response is returned and the caller must close the body.
+// The handlers are applied to the error in the same way as try.Check.
+func CheckResponse(resp *http.Response, err error, handlers ...func(error) error) *http.Response {
+	try.CheckSkip(err, 1, handlers...)
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp
+	}
+	try.CheckSkip(newResponseError(resp), 1, handlers...)
+	return resp
+}
+
+func newResponseError(resp *http.Response) *ResponseError {
+	defer resp.Body.Close()
+	respErr := &ResponseError{StatusCode: resp.StatusCode, Status: resp.Status}
+	if respErr.Status == "" {
+		respErr.Status = fmt.Sprintf("%d %s", resp.StatusCode, http.StatusText(resp.StatusCode))
+	}
+	method, url := requestOf(resp)
+	respErr.Method = method
+	respErr.URL = url
+
+	excerpt, _ := io.ReadAll(io.LimitReader(resp.Body, MaxBodyExcerpt+1))
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxDrain))
+	respErr.Body = bodyExcerpt(excerpt)
+	return respErr
+}
+
+// requestOf gives the method and redacted URL of the request for a response
+func requestOf(resp *http.Response) (method string, url string) {
+	if resp.Request == nil {
+		return "", ""
+	}
+	method = resp.Request.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	if resp.Request.URL != nil {
+		url = redactURL(resp.Request.URL)
+	}
+	return method, url
+}
+
+// redactURL gives the URL without the user information and the fragment, and with the query values replaced by "xxxxx".
+// The query often has credentials such as ?token= or ?api_key=.
+func redactURL(u *url.URL) string {
+	redacted := *u
+	redacted.User = nil
+	redacted.Fragment = ""
+	redacted.RawFragment = ""
+	if redacted.RawQuery != "" {
+		query := redacted.Query()
+		for _, values := range query {
+			for i := range values {
+				values[i] = "xxxxx"
+			}
+		}
+		redacted.RawQuery = query.Encode()
+	}
+	return redacted.String()
+}
+
+func bodyExcerpt(b []byte) string {
+	truncated := len(b) > MaxBodyExcerpt
+	if truncated {
+		b = b[:MaxBodyExcerpt]
+	}
+	// Truncating may have cut a UTF-8 character in half
+	excerpt := strings.TrimSpace(strings.ToValidUTF8(string(b), ""))
+	if truncated {
+		excerpt += "..."
+	}
+	return excerpt
+}
+
+// DecodeJSON decodes the JSON body of a response and closes the body.
+// A decode failure is annotated with the method, the redacted URL (see ResponseError), and the status of the response.
+//
+//	user := try.Check1(tryhttp.DecodeJSON[User](tryhttp.CheckResponse(client.Get(url))))
+func DecodeJSON[T any](resp *http.Response) (T, error) {
+	defer resp.Body.Close()
+	var v T
+	if err := json.NewDecoder(resp.Body).Decode(&v); err != nil {
+		method, url := requestOf(resp)
+		return v, fmt.Errorf("decode JSON from %s %s (%s): %w", method, url, resp.Status, err)
+	}
+	return v, nil
+}
@@ -0,0 +1,91 @@
+package tryhttp_test
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gregwebs/try"
+	"github.com/gregwebs/try/assert"
+	"github.com/gregwebs/try/tryhttp"
+)
+
+type user struct {
+	Name string `json:"name"`
+}
+
+type trackedBody struct {
+	io.Reader
+	closed bool
+}
+
+func (b *trackedBody) Close() error {
+	b.closed = true
+	return nil
+}
+
+func getUser(client *http.Client, u string) (usr user, err error) {
+	defer try.Handlew(&err, "get user")
+	return try.Check1(tryhttp.DecodeJSON[user](tryhttp.CheckResponse(client.Get(u)))), nil
+}
+
+func TestCheckResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ok":
+			io.WriteString(w, `{"name": "Ann"}`)
+		case "/bad-json":
+			io.WriteString(w, `{"name": `)
+		default:
+			http.Error(w, strings.Repeat("missing ", 100), http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	usr, err := getUser(server.Client(), server.URL+"/ok")
+	assert.NoError(err)
+	assert.Equal(usr.Name, "Ann")
+
+	u, _ := url.Parse(server.URL + "/missing")
+	u.User = url.UserPassword("admin", "secret")
+	u.RawQuery = "token=abc123&api_key=k1"
+	_, err = getUser(server.Client(), u.String())
+	var respErr *tryhttp.ResponseError
+	assert.That(errors.As(err, &respErr), "expected a ResponseError")
+	assert.Equal(respErr.StatusCode, http.StatusNotFound)
+	assert.Equal(respErr.Method, http.MethodGet)
+	for _, credential := range []string{"admin", "secret", "abc123", "k1"} {
+		assert.That(!strings.Contains(err.Error(), credential), "the credentials are redacted: "+err.Error())
+	}
+	assert.That(strings.Contains(err.Error(), "get user: GET "+server.URL+"/missing?api_key=xxxxx&token=xxxxx: 404 Not Found: missing missing"), err.Error())
+	assert.That(len(respErr.Body) <= tryhttp.MaxBodyExcerpt+len("...") && strings.HasSuffix(respErr.Body, "..."), respErr.Body)
+
+	_, err = getUser(server.Client(), server.URL+"/bad-json")
+	assert.That(err != nil && strings.Contains(err.Error(), "decode JSON from GET "+server.URL+"/bad-json (200 OK)"), err.Error())
+	assert.That(errors.Is(err, io.ErrUnexpectedEOF), "the decode error is wrapped")
+
+	_, err = getUser(server.Client(), "http://127.0.0.1:0/")
+	var urlErr *url.Error
+	assert.That(errors.As(err, &urlErr), "transport errors are thrown")
+}
+
+func TestCheckResponse_closesBody(t *testing.T) {
+	body := &trackedBody{Reader: strings.NewReader("unavailable")}
+	resp := &http.Response{
+		StatusCode: http.StatusServiceUnavailable,
+		Status:     "503 Service Unavailable",
+		Body:       body,
+		Request:    httptest.NewRequest(http.MethodPost, "http://example.com/jobs", nil),
+	}
+	err := func() (err error) {
+		defer try.Handle(&err, nil)
+		tryhttp.CheckResponse(resp, nil)
+		return nil
+	}()
+	assert.Equal(err.Error(), "POST http://example.com/jobs: 503 Service Unavailable: unavailable")
+	assert.That(body.closed, "the body is closed")
+}
@@ -0,0 +1,259 @@
+/*
+Package tryhttp integrates try with net/http.
+
+Handler adapts a function that returns an error to an http.Handler.
+The function can use try.Check* without deferring a handler.
+
+	http.Handle("/users/", tryhttp.Handler(func(w http.ResponseWriter, r *http.Request) error {
+		user := try.Check1(lookup(r.URL.Path))
+		return json.NewEncoder(w).Encode(user)
+	}, tryhttp.RequestID("X-Request-ID"), tryhttp.Logging(logger)))
+
+An error is written as an RFC 7807 problem+json response with the status given by try.HTTPStatus.
+*/
+package tryhttp
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/gregwebs/try"
+	"github.com/gregwebs/try/internal/panics"
+	"github.com/gregwebs/try/internal/stack"
+)
+
+func init() {
+	// Handle* functions re-panic http.ErrAbortHandler untouched so that net/http aborts the response
+	panics.PassThrough(http.ErrAbortHandler)
+}
+
+// HandlerFunc is an HTTP handler that returns an error
+type HandlerFunc func(w http.ResponseWriter, r *http.Request) error
+
+// Middleware wraps a HandlerFunc.
+// Middleware sees the errors from the handler, including errors thrown by try.Check and panics converted to errors.
+type Middleware func(HandlerFunc) HandlerFunc
+
+// Handler adapts fn to an http.Handler.
+// The middleware is applied in order: the first middleware is the outermost.
+//
+// Errors thrown with try.Check are recovered.
+// A panic is recovered, converted to a try.PanicAnnotated error, and logged with slog.Default() with its stack.
+// The exception is http.ErrAbortHandler, which is re-panicked untouched so that net/http aborts the response.
+//
+// An error is written with WriteProblem unless the handler already wrote a response.
+func Handler(fn HandlerFunc, middleware ...Middleware) http.Handler {
+	return Options{}.Handler(fn, middleware...)
+}
+
+// Options configures Handler
+type Options struct {
+	// Logger logs panics. The default is slog.Default().
+	Logger *slog.Logger
+	// WriteError writes the response for an error. The default is WriteProblem.
+	WriteError func(w http.ResponseWriter, r *http.Request, err error)
+}
+
+// Handler is the same as the Handler function, using the options
+func (o Options) Handler(fn HandlerFunc, middleware ...Middleware) http.Handler {
+	h := recoverPanics(fn)
+	for i := len(middleware) - 1; i >= 0; i-- {
+		h = middleware[i](h)
+	}
+	return handler{fn: h, options: o}
+}
+
+type handler struct {
+	fn      HandlerFunc
+	options Options
+}
+
+func (h handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rw := &responseWriter{ResponseWriter: w}
+	err := h.fn(rw, r)
+	if err == nil {
+		return
+	}
+	var panicked try.PanicAnnotated
+	if errors.As(err, &panicked) {
+		logger := h.options.Logger
+		if logger == nil {
+			logger = slog.Default()
+		}
+		logger.ErrorContext(r.Context(), "panic in HTTP handler",
+			slog.String("method", r.Method), slog.String("path", r.URL.Path), slog.Any("panic", panicked),
+			slog.String("stack", fmt.Sprintf("%+v", panicked)))
+	}
+	if rw.wroteHeader {
+		return
+	}
+	if h.options.WriteError != nil {
+		h.options.WriteError(w, r, err)
+	} else {
+		WriteProblem(w, r, err)
+	}
+}
+
+// recoverPanics recovers errors thrown by try.Check and converts panics to try.PanicAnnotated errors
+func recoverPanics(fn HandlerFunc) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) (err error) {
+		defer func() {
+			rec := recover()
+			if rec == nil {
+				return
+			}
+			if rec == http.ErrAbortHandler {
+				panic(rec)
+			}
+			if err = panics.Thrown(rec); err != nil {
+				return
+			}
+			if annotated, ok := rec.(panics.Annotated); ok {
+				err = annotated
+				return
+			}
+			err = panics.Annotated{Panic: rec, Err: stack.New(fmt.Errorf("%+v", rec), 0), PanicStack: panics.Stack(0)}
+		}()
+		return fn(w, r)
+	}
+}
+
+// responseWriter records whether a response has been started
+type responseWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	w.wroteHeader = true
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *responseWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+// Problem is an RFC 7807 problem details object
+type Problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	Instance  string `json:"instance,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// NewProblem creates the Problem for an error.
+// The status is given by try.HTTPStatus, except that a panic is always a 500.
+// The error message is only given as the detail for a 4xx status so that internal details are not exposed.
+func NewProblem(r *http.Request, err error) Problem {
+	status := httpStatus(err)
+	problem := Problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(status),
+		Status:   status,
+		Instance: r.URL.Path,
+	}
+	if problem.Title == "" {
+		problem.Title = try.KindOf(err).String()
+	}
+	if status < 500 {
+		problem.Detail = err.Error()
+	}
+	if id, ok := try.Fields(err)[requestIDField].(string); ok {
+		problem.RequestID = id
+	}
+	return problem
+}
+
+// httpStatus is try.HTTPStatus, but a panic is a 500 even if a Kind was added to it by a Handle* function
+func httpStatus(err error) int {
+	var panicked try.PanicAnnotated
+	if errors.As(err, &panicked) {
+		return http.StatusInternalServerError
+	}
+	return try.HTTPStatus(err)
+}
+
+// WriteProblem writes an error as an RFC 7807 application/problem+json response
+func WriteProblem(w http.ResponseWriter, r *http.Request, err error) {
+	problem := NewProblem(r, err)
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(problem.Status)
+	_ = json.NewEncoder(w).Encode(problem)
+}
+
+const requestIDField = "request_id"
+
+type requestIDKey struct{}
+
+// RequestIDFrom gives the request ID that was set by the RequestID middleware
+func RequestIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// RequestID is middleware that gives every request an ID.
+// The ID is read from the request header, or generated if the header is missing.
+// It is set in the response header and in the request context (see RequestIDFrom).
+// Errors are annotated with the ID in the "request_id" field (see try.Fields),
+// and it is included in the problem+json response.
+func RequestID(header string) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) error {
+			id := r.Header.Get(header)
+			if id == "" {
+				id = newRequestID()
+			}
+			w.Header().Set(header, id)
+			r = r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id))
+			err := next(w, r)
+			if err != nil {
+				err = try.Fmtkv("", requestIDField, id)(err)
+			}
+			return err
+		}
+	}
+}
+
+func newRequestID() string {
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// Logging is middleware that logs errors with the method, path, and status of the request.
+// The request ID is logged when Logging is used after the RequestID middleware.
+func Logging(logger *slog.Logger) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) error {
+			err := next(w, r)
+			if err != nil {
+				status := httpStatus(err)
+				level := slog.LevelWarn
+				if status >= 500 {
+					level = slog.LevelError
+				}
+				attrs := []slog.Attr{
+					slog.String("method", r.Method), slog.String("path", r.URL.Path),
+					slog.Int("status", status), slog.Any("error", err),
+				}
+				if id := RequestIDFrom(r.Context()); id != "" {
+					attrs = append(attrs, slog.String(requestIDField, id))
+				}
+				logger.LogAttrs(r.Context(), level, "HTTP handler error", attrs...)
+			}
+			return err
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package tryhttp_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gregwebs/try"
+	"github.com/gregwebs/try/assert"
+	"github.com/gregwebs/try/tryhttp"
+)
+
+var errNoUser = try.NewSentinel(try.NotFound, "no such user")
+
+func lookup(name string) (string, error) {
+	if name == "ann" {
+		return "Ann", nil
+	}
+	return "", errNoUser
+}
+
+func serve(h http.Handler, target string) (*httptest.ResponseRecorder, tryhttp.Problem) {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+	var problem tryhttp.Problem
+	if rec.Header().Get("Content-Type") == "application/problem+json" {
+		try.Check(json.NewDecoder(rec.Body).Decode(&problem))
+	}
+	return rec, problem
+}
+
+func TestHandler_thrown(t *testing.T) {
+	h := tryhttp.Handler(func(w http.ResponseWriter, r *http.Request) error {
+		name := try.Checkw1(lookup(r.URL.Query().Get("name")))("lookup")
+		_, err := fmt.Fprint(w, name)
+		return err
+	})
+
+	rec, _ := serve(h, "/users?name=ann")
+	assert.Equal(rec.Code, http.StatusOK)
+	assert.Equal(rec.Body.String(), "Ann")
+
+	rec, problem := serve(h, "/users?name=bob")
+	assert.Equal(rec.Code, http.StatusNotFound)
+	assert.Equal(problem.Status, http.StatusNotFound)
+	assert.Equal(problem.Title, "Not Found")
+	assert.Equal(problem.Detail, "lookup: no such user")
+	assert.Equal(problem.Instance, "/users")
+}
+
+func TestHandler_internalDetailHidden(t *testing.T) {
+	h := tryhttp.Handler(func(w http.ResponseWriter, r *http.Request) error {
+		return errors.New("database password is wrong")
+	})
+	rec, problem := serve(h, "/")
+	assert.Equal(rec.Code, http.StatusInternalServerError)
+	assert.Equal(problem.Detail, "")
+}
+
+func TestHandler_panic(t *testing.T) {
+	var logs bytes.Buffer
+	h := tryhttp.Options{Logger: slog.New(slog.NewTextHandler(&logs, nil))}.Handler(func(w http.ResponseWriter, r *http.Request) error {
+		var m map[string]int
+		m["x"] = 1
+		return nil
+	})
+	rec, problem := serve(h, "/")
+	assert.Equal(rec.Code, http.StatusInternalServerError)
+	assert.Equal(problem.Status, http.StatusInternalServerError)
+	assert.That(strings.Contains(logs.String(), "assignment to entry in nil map"), logs.String())
+	assert.That(strings.Contains(logs.String(), "tryhttp_test.go"), "the stack is logged: "+logs.String())
+	assert.That(strings.Contains(logs.String(), `stack="panic: assignment to entry in nil map\n`), "the stack starts at the panic: "+logs.String())
+}
+
+func TestHandler_panicWithKind(t *testing.T) {
+	h := tryhttp.Options{Logger: slog.New(slog.NewTextHandler(io.Discard, nil))}.Handler(func(w http.ResponseWriter, r *http.Request) (err error) {
+		defer try.HandleKind(&err, try.NotFound)
+		var m map[string]int
+		m["x"] = 1
+		return nil
+	})
+	rec, problem := serve(h, "/")
+	assert.Equal(rec.Code, http.StatusInternalServerError)
+	assert.Equal(problem.Status, http.StatusInternalServerError)
+	assert.Equal(problem.Detail, "", "the panic is not exposed")
+}
+
+func TestHandler_abort(t *testing.T) {
+	h := tryhttp.Handler(func(w http.ResponseWriter, r *http.Request) error {
+		panic(http.ErrAbortHandler)
+	})
+	defer func() {
+		assert.That(recover() == http.ErrAbortHandler, "ErrAbortHandler is re-panicked")
+	}()
+	serve(h, "/")
+	t.Fatal("expected a panic")
+}
+
+func TestHandler_abortHandled(t *testing.T) {
+	h := tryhttp.Handler(func(w http.ResponseWriter, r *http.Request) (err error) {
+		defer try.Handlew(&err, "handler")
+		panic(http.ErrAbortHandler)
+	})
+	defer func() {
+		assert.That(recover() == http.ErrAbortHandler, "ErrAbortHandler is re-panicked through Handle*")
+	}()
+	serve(h, "/")
+	t.Fatal("expected a panic")
+}
+
+func TestHandler_alreadyWritten(t *testing.T) {
+	h := tryhttp.Handler(func(w http.ResponseWriter, r *http.Request) error {
+		w.WriteHeader(http.StatusAccepted)
+		return errors.New("too late")
+	})
+	rec, _ := serve(h, "/")
+	assert.Equal(rec.Code, http.StatusAccepted)
+	assert.Equal(rec.Body.Len(), 0)
+}
+
+func TestMiddleware(t *testing.T) {
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, nil))
+	var seen string
+	h := tryhttp.Handler(func(w http.ResponseWriter, r *http.Request) error {
+		seen = tryhttp.RequestIDFrom(r.Context())
+		try.CheckKind(errors.New("bad name"), try.InvalidArgument)
+		return nil
+	}, tryhttp.RequestID("X-Request-ID"), tryhttp.Logging(logger))
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/users", nil)
+	req.Header.Set("X-Request-ID", "req-1")
+	h.ServeHTTP(rec, req)
+
+	assert.Equal(seen, "req-1")
+	assert.Equal(rec.Code, http.StatusBadRequest)
+	assert.Equal(rec.Header().Get("X-Request-ID"), "req-1")
+	var problem tryhttp.Problem
+	try.Check(json.NewDecoder(rec.Body).Decode(&problem))
+	assert.Equal(problem.RequestID, "req-1")
+	assert.That(strings.Contains(logs.String(), "level=WARN"), logs.String())
+	assert.That(strings.Contains(logs.String(), "status=400"), logs.String())
+	assert.That(strings.Contains(logs.String(), "request_id=req-1"), logs.String())
+
+	rec, _ = serve(h, "/users")
+	assert.That(rec.Header().Get("X-Request-ID") != "", "a request ID is generated")
+}